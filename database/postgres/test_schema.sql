@@ -190,6 +190,8 @@ CREATE TABLE test_schema."user" (
 	user_token           uuid DEFAULT uuid_generate_v1mc() NOT NULL ,
 	enabled              bool DEFAULT true NOT NULL ,
 	aka_id               int   ,
+	version              int DEFAULT 1 NOT NULL ,
+	tags                 text[]   ,
 	CONSTRAINT lookup_email UNIQUE ( email ) ,
 	CONSTRAINT pk_user PRIMARY KEY ( user_id )
  );
@@ -198,6 +200,8 @@ COMMENT ON CONSTRAINT lookup_email ON test_schema."user" IS 'Any index that is p
 
 CREATE INDEX lookup_name ON test_schema."user" ( first_name, last_name );
 
+COMMENT ON COLUMN test_schema."user".version IS 'Incremented by every update, for optimistic concurrency';
+
 COMMENT ON TABLE test_schema."user" IS 'This table will help test secondary lookup via email.\n\n#service: user';
 
 CREATE TABLE test_schema.user_product_part ( 
@@ -7,7 +7,6 @@ require (
 	github.com/google/uuid v1.3.0
 	github.com/iancoleman/strcase v0.2.0
 	github.com/lib/pq v1.10.7
+	google.golang.org/protobuf v1.26.0
 	gopkg.in/yaml.v3 v3.0.1
 )
-
-require google.golang.org/protobuf v1.26.0 // indirect
@@ -0,0 +1,77 @@
+package dbmap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Drift A generated file that differs from the file on disk, with the unified diff from the file on disk to the
+// generated file
+type Drift struct {
+	Path string
+	Diff string
+}
+
+// Check Runs the generators of the configuration into memory and compares them with the files under proto.path and
+// output.path. Returns every file that generating would create, change or remove, which is empty when the files on
+// disk are up to date with the database
+func Check(ctx context.Context, cfg Config, database *Database) ([]Drift, error) {
+	fs := NewMemoryFileSystem()
+	if err := RunGenerators(ctx, cfg, database, fs); err != nil {
+		return nil, err
+	}
+
+	drifts := make([]Drift, 0)
+	for _, name := range fs.Names() {
+		current, err := os.ReadFile(name)
+		fromName := name
+		if os.IsNotExist(err) {
+			fromName = os.DevNull
+		} else if err != nil {
+			return nil, err
+		}
+
+		if diff := UnifiedDiff(fromName, name, string(current), fs.Files[name].String()); diff != "" {
+			drifts = append(drifts, Drift{Path: name, Diff: diff})
+		}
+	}
+
+	// A proto left behind by a table that has been dropped or excluded since it was generated
+	for _, schema := range database.Schemas {
+		if !cfg.generatesProtos() {
+			break
+		}
+
+		existing, err := filepath.Glob(ProtoFilename(cfg, schema.SchemaName, "*"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range existing {
+			if _, ok := fs.Files[name]; ok {
+				continue
+			}
+			current, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			drifts = append(drifts, Drift{Path: name, Diff: UnifiedDiff(name, os.DevNull, string(current), "")})
+		}
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].Path < drifts[j].Path
+	})
+	return drifts, nil
+}
+
+func (cfg Config) generatesProtos() bool {
+	for _, generator := range cfg.Generators() {
+		if _, ok := generator.(protoGenerator); ok {
+			return true
+		}
+	}
+	return false
+}
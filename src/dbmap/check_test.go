@@ -0,0 +1,45 @@
+package dbmap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	var cfg Config
+	cfg.Proto.Path = t.TempDir()
+	cfg.Proto.Version = "proto3"
+
+	table := Table{TableSchema: "test_schema", TableName: "user", Columns: []Column{
+		{TableSchema: "test_schema", TableName: "user", ColumnName: "user_id", UdtName: "int8"},
+	}}
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{table}}}}
+
+	if err := GenerateProto(cfg, database); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(cfg.Proto.Path, "test_schema", "dropped.proto")
+	if err := os.WriteFile(stale, []byte("message Dropped {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	database.Schemas[0].Tables[0].Columns = append(table.Columns,
+		Column{TableSchema: "test_schema", TableName: "user", ColumnName: "email", UdtName: "varchar"})
+	drifts, err := Check(context.Background(), cfg, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(drifts) != 2 {
+		t.Fatalf("Expected 2 drifts but got %d", len(drifts))
+	}
+	if drifts[0].Path != stale || !strings.Contains(drifts[0].Diff, "-message Dropped {}") {
+		t.Errorf("Expected the dropped proto to be removed but got %+v", drifts[0])
+	}
+	if !strings.Contains(drifts[1].Diff, "+    string email = 2;") {
+		t.Errorf("Expected the email field to be added but got %s", drifts[1].Diff)
+	}
+}
@@ -1,5 +1,360 @@
 package dbmap
 
+import (
+	"fmt"
+	"github.com/iancoleman/strcase"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Identifiers and string literals within an index expression, so that column references can be found without
+// matching inside a literal
+var expressionTokens = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")+"|[A-Za-z_][A-Za-z0-9_$]*`)
+
 func GenerateCode(cfg Config, database *Database) error {
+	return GenerateCodeTo(DiskFileSystem{}, cfg, database)
+}
+
+// GenerateCodeTo Writes the code for every table to the file system, which can be in memory. The code of a table is
+// written into the package of its proto messages, which it reads and writes, so a NULL column needs the optional
+// fields of proto2. Code is only generated for postgres. A table with a column that the code cannot read or write is
+// left out with a warning. The returned error is a *GenerateError identifying the table that failed
+func GenerateCodeTo(fs FileSystem, cfg Config, database *Database) error {
+	if cfg.Proto.Version != "proto2" {
+		cfg.Logf("[warning] Not generating code, which needs the optional fields of proto2 to read NULL columns\n")
+		return nil
+	} else if cfg.Database.Provider == "mariadb" {
+		cfg.Logf("[warning] Not generating code, which is only generated for postgres\n")
+		return nil
+	}
+
+	for _, schema := range database.Schemas {
+		path := filepath.Join(cfg.Output.Path, schema.SchemaName)
+		if err := fs.MkdirAll(path); err != nil {
+			cfg.Logf("FAILED to create output path with permission 0755 - %s : %s\n", path, err)
+			return &GenerateError{Schema: schema.SchemaName, Path: path, Err: err}
+		}
+
+		for _, table := range schema.Tables {
+			t, err := newCodeTable(cfg, table)
+			if err != nil {
+				cfg.Logf("[warning] Not generating code for %s.%s, since %s\n", table.TableSchema, table.TableName, err)
+				continue
+			}
+
+			cfg.Logf("%s/%s%s.go\n", table.TableSchema, table.TableName, cfg.Output.Suffix)
+			filename := CodeFilename(cfg, table.TableSchema, table.TableName)
+			if err := writeCodeFile(fs, t, filename); err != nil {
+				cfg.Logf("Failed to write code for table %s in path %s : %s\n", table.TableName, path, err)
+				return &GenerateError{Schema: table.TableSchema, Table: table.TableName, Path: filename, Err: err}
+			}
+		}
+	}
 	return nil
 }
+
+// CodeFilename Returns the path of the code generated for the table
+func CodeFilename(cfg Config, schemaName string, tableName string) string {
+	return filepath.Join(cfg.Output.Path, schemaName, tableName+cfg.Output.Suffix+".go")
+}
+
+func writeCodeFile(fs FileSystem, t *codeTable, filename string) error {
+	code, err := writeCode(t)
+	if err != nil {
+		return err
+	}
+
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(code); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// IsExpression Returns true if the key of the index is an expression rather than a column
+func (index Index) IsExpression(key string) bool {
+	for _, expression := range index.Expressions {
+		if expression == key {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEqualityLookup Returns true if rows can be looked up by equality on the keys of the index. Only a btree or hash
+// index supports equality, a gin, gist or brin index is for containment, range or nearest neighbour searches
+func (index Index) IsEqualityLookup() bool {
+	return index.Method == "" || index.Method == "btree" || index.Method == "hash"
+}
+
+// LookupWhere Returns the condition of a lookup by the keys of the index, with the parameters starting after offset.
+// For an expression key, the same expression is applied to the parameter whenever it references a single column so
+// that the index is used, e.g. lower(email) = lower($1). The predicate of a partial index is always included.
+func (index Index) LookupWhere(table Table, offset int) string {
+	conditions := make([]string, 0)
+	for i, key := range index.Columns {
+		param := fmt.Sprintf("$%d", offset+i+1)
+		if !index.IsExpression(key) {
+			conditions = append(conditions, key+" = "+param)
+		} else if bound, ok := bindExpression(key, table, param); ok {
+			conditions = append(conditions, key+" = "+bound)
+		} else {
+			conditions = append(conditions, "("+key+") = "+param)
+		}
+	}
+
+	if index.Predicate != "" {
+		conditions = append(conditions, "("+index.Predicate+")")
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// bindExpression Replaces the column referenced by the expression with the parameter. This is only possible when the
+// expression references exactly one column of the table.
+func bindExpression(expression string, table Table, param string) (string, bool) {
+	columns := make(map[string]bool)
+	for _, column := range table.Columns {
+		columns[column.ColumnName] = true
+	}
+
+	referenced := ""
+	tokens := make([][]int, 0)
+	for _, token := range expressionTokens.FindAllStringIndex(expression, -1) {
+		if name, ok := columnReference(expression, token, columns); ok {
+			if referenced != "" && referenced != name {
+				return "", false
+			}
+			referenced = name
+			tokens = append(tokens, token)
+		}
+	}
+	if referenced == "" {
+		return "", false
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, token := range tokens {
+		b.WriteString(expression[last:token[0]])
+		b.WriteString(param)
+		last = token[1]
+	}
+	b.WriteString(expression[last:])
+	return b.String(), true
+}
+
+// columnReference Returns the column name if the token is a reference to a column, rather than a literal, a type cast
+// or the name of a function
+func columnReference(expression string, token []int, columns map[string]bool) (string, bool) {
+	name := strings.Trim(expression[token[0]:token[1]], `"`)
+	if strings.HasPrefix(expression[token[0]:], "'") || strings.HasSuffix(expression[:token[0]], "::") ||
+		strings.HasPrefix(strings.TrimLeft(expression[token[1]:], " "), "(") {
+		return "", false
+	}
+	return name, columns[name]
+}
+
+// FilterType Returns the type of the field of the column in the filter of the generated Find function, or an empty
+// string when the column cannot be filtered
+func FilterType(column Column) string {
+	if column.ArrayDims > 0 || column.Composite != nil {
+		return ""
+	}
+
+	udtName := column.UdtName
+	switch {
+	case strings.HasPrefix(udtName, "bool"):
+		return "*bool"
+	case udtName == "int2" || udtName == "int4" || udtName == "smallint" || udtName == "integer" ||
+		udtName == "serial" || udtName == "smallserial":
+		return "*model.Int32Filter"
+	case strings.HasPrefix(udtName, "char") || strings.HasPrefix(udtName, "varchar") || udtName == "bpchar" ||
+		udtName == "text" || udtName == "citext" || udtName == "uuid" || udtName == "name":
+		return "*model.StringFilter"
+	}
+	return ""
+}
+
+// FilterColumns Returns the columns of the table that the generated Find function filters and sorts by. With
+// strict_filters only the columns that are the first key of an index are returned, since a condition on any other
+// column cannot use an index and scans the table.
+func (cfg Config) FilterColumns(table Table) []Column {
+	leading := make(map[string]bool)
+	for _, index := range table.Indexes {
+		if len(index.Columns) > 0 && !index.IsExpression(index.Columns[0]) && index.Predicate == "" {
+			leading[index.Columns[0]] = true
+		}
+	}
+
+	columns := make([]Column, 0)
+	for _, column := range table.Columns {
+		if FilterType(column) == "" || (cfg.Generator.StrictFilters && !leading[column.ColumnName]) {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// UpsertIndexes Returns the indexes that an UpsertBy function is generated for, which are the primary key and the
+// unique indexes of plain columns. A primary key assigned by a sequence is left out, since a new row never has it.
+// Nothing is upserted when the table does not generate both create and update.
+func (cfg Config) UpsertIndexes(table Table) []Index {
+	if !cfg.GeneratesOperation(table.TableSchema, table.TableName, "create") ||
+		!cfg.GeneratesOperation(table.TableSchema, table.TableName, "update") {
+		return nil
+	}
+
+	sequences := make(map[string]bool)
+	for _, column := range table.Columns {
+		sequences[column.ColumnName] = column.IsSequence
+	}
+
+	indexes := make([]Index, 0)
+	for _, index := range table.Indexes {
+		if index.IndexType == NonUnique || len(index.Expressions) > 0 || len(index.Columns) == 0 {
+			continue
+		}
+		if index.IndexType == PrimaryKey && sequences[index.Columns[0]] {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes
+}
+
+// UpsertName Returns the name of the upsert on the index, such as UpsertByEmail or UpsertByFirstNameAndLastName
+func (index Index) UpsertName() string {
+	names := make([]string, len(index.Columns))
+	for i, column := range index.Columns {
+		names[i] = strcase.ToCamel(column)
+	}
+	return "UpsertBy" + strings.Join(names, "And")
+}
+
+// UpsertColumns Returns the columns that the upsert on the index overwrites when a row with the same key exists. These
+// are the upsert_columns of the table, or else every column that is inserted, including those written by an insert
+// transform. The keys of the index and the version column are never overwritten.
+func (cfg Config) UpsertColumns(table Table, index Index) []string {
+	keys := make(map[string]bool)
+	for _, key := range index.Columns {
+		keys[key] = true
+	}
+	// The version column is moved forward rather than overwritten
+	if version, ok := cfg.VersionColumn(table); ok {
+		keys[version.ColumnName] = true
+	}
+
+	candidates := cfg.TableOverride(table.TableSchema, table.TableName).UpsertColumns
+	if candidates == nil {
+		for _, column := range table.Columns {
+			if !column.IsSequence {
+				candidates = append(candidates, column.ColumnName)
+			}
+		}
+		for _, transform := range cfg.Generator.Transforms {
+			if MatchTable(transform.Tablename, table.TableSchema, table.TableName) {
+				for _, xform := range transform.Xforms.Insert {
+					candidates = append(candidates, xform.Columnname)
+				}
+			}
+		}
+	}
+
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, column := range candidates {
+		if !keys[column] && !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// UpsertClause Returns the ON CONFLICT clause that follows the VALUES of the INSERT to make it an upsert on the index.
+// Upserts are only generated for postgres, like the rest of the code. When no column is overwritten the first key is
+// set to itself, so that the existing row is still returned. The extra assignments, such as moving a version column
+// forward, are added as they are.
+func UpsertClause(index Index, columns []string, extra ...string) string {
+	if len(columns) == 0 && len(extra) == 0 {
+		columns = index.Columns[:1]
+	}
+
+	assignments := make([]string, 0, len(columns)+len(extra))
+	for _, column := range columns {
+		assignments = append(assignments, column+" = EXCLUDED."+column)
+	}
+	assignments = append(assignments, extra...)
+
+	target := "(" + strings.Join(index.Columns, ", ") + ")"
+	if index.Predicate != "" {
+		target += " WHERE " + index.Predicate
+	}
+	return "ON CONFLICT " + target + " DO UPDATE SET " + strings.Join(assignments, ", ")
+}
+
+// MaskColumn A column that the generated UpdateMask function can set, which is written as a model.MaskColumn. Value is
+// the SQL of the new value with a %s for the parameter of each of the Paths, the fields of the proto message it reads.
+type MaskColumn struct {
+	Column string
+	Value  string
+	Paths  []string
+}
+
+// MaskColumns Returns the columns that the generated UpdateMask function of the table can set. A column is set from
+// its own field, unless it is written by an update transform, which sets it from the fields that the transform
+// references. The primary key, which the update is by, and the version column are never set.
+func (cfg Config) MaskColumns(table Table) []MaskColumn {
+	xforms := make(map[string]Xform)
+	for _, transform := range cfg.Generator.Transforms {
+		if MatchTable(transform.Tablename, table.TableSchema, table.TableName) {
+			for _, xform := range transform.Xforms.Update {
+				xforms[xform.Columnname] = xform
+			}
+		}
+	}
+
+	fields := make(map[string]string)
+	for _, column := range table.Columns {
+		fields[column.ColumnName] = cfg.FieldName(column)
+	}
+
+	version, _ := cfg.VersionColumn(table)
+	columns := make([]MaskColumn, 0)
+	for _, column := range table.Columns {
+		if column.IsPrimaryKey || column.IsSequence || column.ColumnName == version.ColumnName {
+			continue
+		}
+		if _, ok := xforms[column.ColumnName]; !ok {
+			columns = append(columns, MaskColumn{Column: column.ColumnName, Value: "%s",
+				Paths: []string{fields[column.ColumnName]}})
+		}
+	}
+
+	for _, transform := range cfg.Generator.Transforms {
+		if !MatchTable(transform.Tablename, table.TableSchema, table.TableName) {
+			continue
+		}
+		for _, xform := range transform.Xforms.Update {
+			// A reference is to a column of the table or to a field of a select transform, such as lat
+			paths := make([]string, 0)
+			value := xformReference.ReplaceAllStringFunc(strings.ReplaceAll(xform.Xform, "%", "%%"),
+				func(reference string) string {
+					name := reference[1:]
+					if field, ok := fields[name]; ok {
+						name = field
+					}
+					paths = append(paths, name)
+					return "%s"
+				})
+			columns = append(columns, MaskColumn{Column: xform.Columnname, Value: value, Paths: paths})
+		}
+	}
+	return columns
+}
@@ -0,0 +1,397 @@
+package dbmap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookupWhere(t *testing.T) {
+	table := Table{TableName: "user", TableSchema: "test_schema", Columns: []Column{
+		{ColumnName: "user_id"},
+		{ColumnName: "email"},
+		{ColumnName: "first_name"},
+		{ColumnName: "last_name"},
+		{ColumnName: "deleted_at"},
+	}}
+
+	cases := []struct {
+		index    Index
+		offset   int
+		expected string
+	}{
+		{Index{Columns: []string{"email"}, Method: "btree"}, 0, "email = $1"},
+		{Index{Columns: []string{"first_name", "last_name"}, Method: "btree"}, 1,
+			"first_name = $2 AND last_name = $3"},
+		{Index{Columns: []string{"lower(email::text)"}, Expressions: []string{"lower(email::text)"}, Method: "btree"},
+			0, "lower(email::text) = lower($1::text)"},
+		{Index{Columns: []string{"lower(email::text)"}, Expressions: []string{"lower(email::text)"}, Method: "btree",
+			Predicate: "deleted_at IS NULL"}, 0, "lower(email::text) = lower($1::text) AND (deleted_at IS NULL)"},
+		{Index{Columns: []string{"(first_name::text || ' email '::text) || last_name::text"},
+			Expressions: []string{"(first_name::text || ' email '::text) || last_name::text"}}, 0,
+			"((first_name::text || ' email '::text) || last_name::text) = $1"},
+	}
+
+	for i, c := range cases {
+		if where := c.index.LookupWhere(table, c.offset); where != c.expected {
+			t.Errorf("%d) Expected %s but got %s", i, c.expected, where)
+		}
+	}
+}
+
+func TestIsEqualityLookup(t *testing.T) {
+	if !(Index{Method: "btree"}).IsEqualityLookup() || !(Index{Method: "hash"}).IsEqualityLookup() {
+		t.Error("Expected btree and hash indexes to support equality")
+	}
+
+	if (Index{Method: "gin"}).IsEqualityLookup() || (Index{Method: "gist"}).IsEqualityLookup() {
+		t.Error("Expected gin and gist indexes to not support equality")
+	}
+}
+
+// The test_schema.user table as the example config reads it, without the excluded geog and pword_hash. The types are
+// named as the provider reads them, by their udt_name::regtype
+func exampleUser() Table {
+	column := func(name string, dataType string, nullable bool) Column {
+		return Column{TableSchema: "test_schema", TableName: "user", ColumnName: name, DataType: dataType,
+			UdtName: dataType, IsNullable: nullable}
+	}
+
+	userId := column("user_id", "integer", false)
+	userId.IsSequence, userId.IsPrimaryKey = true, true
+	tags := column("tags", "ARRAY", true)
+	tags.UdtName, tags.ElementType, tags.ArrayDims = "text[]", "text", 1
+	return Table{TableSchema: "test_schema", TableName: "user", Columns: []Column{
+		userId,
+		column("first_name", "character varying", true),
+		column("last_name", "character varying", true),
+		column("email", "character varying", false),
+		column("user_token", "uuid", false),
+		column("enabled", "boolean", false),
+		column("aka_id", "integer", true),
+		column("version", "integer", false),
+		tags,
+	}, Indexes: []Index{
+		{IndexName: "pk_user", IndexType: PrimaryKey, Columns: []string{"user_id"}, Method: "btree"},
+		{IndexName: "lookup_email", IndexType: Unique, Columns: []string{"email"}, Method: "btree"},
+		{IndexName: "lookup_name", IndexType: NonUnique, Columns: []string{"first_name", "last_name"},
+			Method: "btree"},
+	}, Relations: []ForeignRelation{{ForeignSchema: "test_schema", ForeignTable: "user",
+		Columns: []ForeignColumns{{LocalColumn: "aka_id", ForeignColumn: "user_id"}}}},
+		SkippedColumns: []string{"geog", "pword_hash"}}
+}
+
+// TestGenerateCode The code of the example config is model/test_schema/user_db.go, which the tests of that package run
+// against the example database
+func TestGenerateCode(t *testing.T) {
+	var cfg Config
+	if err := ReadFile(&cfg, "../../config/go_dbmap.yml"); err != nil {
+		t.Fatal(err)
+	}
+	// The example protos are compiled without embedded relationships
+	cfg.EmbedRelationships = false
+
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{exampleUser()}}}}
+	fs := NewMemoryFileSystem()
+	if err := GenerateCodeTo(fs, cfg, database); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join("output", "test_schema", "user_db.go")
+	if names := fs.Names(); !reflect.DeepEqual(names, []string{filename}) {
+		t.Fatalf("Expected only %s but got %v", filename, names)
+	}
+	expected, err := os.ReadFile("../model/test_schema/user_db.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := fs.Files[filename].String(); code != string(expected) {
+		t.Errorf("The generated code is not user_db.go:\n%s", UnifiedDiff("user_db.go", filename, string(expected),
+			code))
+	}
+}
+
+func TestGenerateCodeSkipped(t *testing.T) {
+	var log strings.Builder
+	cfg := Config{Log: &log}
+	cfg.Proto.Version = "proto2"
+	cfg.Output.Path = "output"
+
+	table := Table{TableSchema: "public", TableName: "event", Columns: []Column{
+		{TableSchema: "public", TableName: "event", ColumnName: "event_id", UdtName: "bigint", IsPrimaryKey: true},
+		{TableSchema: "public", TableName: "event", ColumnName: "starts_at", UdtName: "timestamp with time zone"},
+	}}
+	if _, err := newCodeTable(cfg, table); err == nil || err.Error() != "starts_at is of the type timestamp with "+
+		"time zone, which cannot be read into a proto scalar" {
+		t.Errorf("Expected the timestamp to not be read but got %v", err)
+	}
+
+	database := &Database{Schemas: []Schema{{SchemaName: "public", Tables: []Table{table}}}}
+	fs := NewMemoryFileSystem()
+	if err := GenerateCodeTo(fs, cfg, database); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.Files) != 0 {
+		t.Errorf("Expected no code but got %v", fs.Names())
+	}
+	expected := "[warning] Not generating code for public.event, since starts_at is of the type timestamp with time " +
+		"zone, which cannot be read into a proto scalar\n"
+	if log.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, log.String())
+	}
+
+	log.Reset()
+	cfg.Proto.Version = "proto3"
+	table.Columns = table.Columns[:1]
+	database.Schemas[0].Tables[0] = table
+	if err := GenerateCodeTo(fs, cfg, database); err != nil || len(fs.Files) != 0 ||
+		!strings.Contains(log.String(), "proto2") {
+		t.Errorf("Expected proto3 to generate no code but got %v - %v - %q", err, fs.Names(), log.String())
+	}
+}
+
+func TestGenerateCodeKeys(t *testing.T) {
+	config := validConfig + `  tables:
+    -
+      table: "legacy.account"
+      columns:
+        -
+          column: "balance"
+          go_type: "github.com/shopspring/decimal.Decimal"
+          proto_type: "string"
+`
+	var cfg Config
+	if err := ReadFile(&cfg, writeConfig(t, config)); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Proto.Version = "proto2"
+
+	column := func(name string, udtName string, isPrimaryKey bool) Column {
+		return Column{TableSchema: "legacy", TableName: "account", ColumnName: name, UdtName: udtName,
+			IsPrimaryKey: isPrimaryKey}
+	}
+	table := Table{TableSchema: "legacy", TableName: "account", Columns: []Column{
+		column("tenant_id", "integer", true), column("account_id", "bigint", true), column("balance", "numeric", false),
+	}, Indexes: []Index{
+		{IndexName: "pk_account", IndexType: PrimaryKey, Columns: []string{"tenant_id", "account_id"}},
+	}}
+	database := &Database{Schemas: []Schema{{SchemaName: "legacy", Tables: []Table{table}}}}
+	fs := NewMemoryFileSystem()
+	if err := GenerateCodeTo(fs, cfg, database); err != nil {
+		t.Fatal(err)
+	}
+
+	code := fs.Files[CodeFilename(cfg, "legacy", "account")].String()
+	expected := []string{
+		"\t\"github.com/shopspring/decimal\"\n",
+		"balance   decimal.Decimal\n",
+		"tenantId:  *m.TenantId,\n",
+		`" WHERE tenant_id = $1 AND account_id = $2"`,
+		"func (m *Account) Read(ctx context.Context, db model.DBTX, tenantId int32, accountId int64) (err error) {",
+		`" SET balance = $3 WHERE tenant_id = $1 AND account_id = $2 RETURNING "`,
+		`" WHERE (tenant_id, account_id) > ($2, $3) ORDER BY tenant_id, account_id LIMIT $1"`,
+		"after, err := model.DecodeCursor(cursor, &afterTenantId, &afterAccountId)",
+		"result, err := db.ExecContext(ctx, accountDeleteStr, m.TenantId, m.AccountId)",
+	}
+	for _, e := range expected {
+		if !strings.Contains(code, e) {
+			t.Errorf("Expected the code to contain %q but got\n%s", e, code)
+		}
+	}
+}
+
+func TestGenerateCodeComposite(t *testing.T) {
+	var cfg Config
+	if err := ReadFile(&cfg, writeConfig(t, validConfig)); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Proto.Version = "proto2"
+
+	attribute := func(typeName string, name string, udtName string) Column {
+		return Column{TableSchema: "shop", TableName: typeName, ColumnName: name, UdtName: udtName, IsNullable: true}
+	}
+	point := &CompositeType{TypeSchema: "shop", TypeName: "point_t", Attributes: []Column{
+		attribute("point_t", "lat", "double precision"), attribute("point_t", "lon", "double precision"),
+	}}
+	location := attribute("address_t", "location", "shop.point_t")
+	location.DataType, location.Composite = "USER-DEFINED", point
+	address := &CompositeType{TypeSchema: "shop", TypeName: "address_t", Attributes: []Column{
+		attribute("address_t", "street", "text"), attribute("address_t", "zip", "integer"), location,
+	}}
+
+	table := Table{TableSchema: "shop", TableName: "customer", Columns: []Column{
+		{TableSchema: "shop", TableName: "customer", ColumnName: "customer_id", UdtName: "integer",
+			IsPrimaryKey: true, IsSequence: true},
+		{TableSchema: "shop", TableName: "customer", ColumnName: "address", DataType: "USER-DEFINED",
+			UdtName: "shop.address_t", IsNullable: true, Composite: address},
+	}, Indexes: []Index{
+		{IndexName: "pk_customer", IndexType: PrimaryKey, Columns: []string{"customer_id"}},
+	}}
+	database := &Database{Schemas: []Schema{{SchemaName: "shop", Tables: []Table{table}}}}
+	fs := NewMemoryFileSystem()
+	if err := GenerateCodeTo(fs, cfg, database); err != nil {
+		t.Fatal(err)
+	}
+
+	code := fs.Files[CodeFilename(cfg, "shop", "customer")].String()
+	expected := []string{
+		"\t\"database/sql/driver\"\n",
+		"type customerPointT struct {",
+		"address    customerAddressT\n",
+		"address:    toCustomerAddressT(m.Address),",
+		"m.Address = fromCustomerAddressT(n.address)",
+	}
+	for _, e := range expected {
+		if !strings.Contains(code, e) {
+			t.Errorf("Expected the code to contain %q but got\n%s", e, code)
+		}
+	}
+	// A nested type is declared before the type that has an attribute of it
+	if strings.Index(code, "type customerPointT") > strings.Index(code, "type customerAddressT") {
+		t.Error("Expected point_t to be declared before address_t")
+	}
+
+	// An array of a composite type cannot be read
+	table.Columns[1].DataType, table.Columns[1].ArrayDims = "ARRAY", 1
+	if _, err := newCodeTable(cfg, table); err == nil {
+		t.Error("Expected an array of a composite type to not be generated")
+	}
+}
+
+func TestFilterColumns(t *testing.T) {
+	table := Table{TableName: "user", TableSchema: "test_schema", Columns: []Column{
+		{ColumnName: "user_id", UdtName: "int4"},
+		{ColumnName: "first_name", UdtName: "varchar"},
+		{ColumnName: "last_name", UdtName: "varchar"},
+		{ColumnName: "email", UdtName: "text"},
+		{ColumnName: "enabled", UdtName: "bool"},
+		{ColumnName: "tags", UdtName: "_text", ArrayDims: 1},
+		{ColumnName: "geog", UdtName: "geography"},
+		{ColumnName: "deleted_at", UdtName: "timestamptz"},
+	}, Indexes: []Index{
+		{IndexName: "pk_user", IndexType: PrimaryKey, Columns: []string{"user_id"}},
+		{IndexName: "lookup_name", IndexType: NonUnique, Columns: []string{"first_name", "last_name"}},
+		{IndexName: "lookup_email", IndexType: Unique, Columns: []string{"lower(email)"},
+			Expressions: []string{"lower(email)"}},
+		{IndexName: "idx_enabled", IndexType: NonUnique, Columns: []string{"enabled"}, Predicate: "enabled"},
+	}}
+
+	names := func(columns []Column) string {
+		result := make([]string, 0)
+		for _, column := range columns {
+			result = append(result, column.ColumnName+" "+FilterType(column))
+		}
+		return strings.Join(result, ", ")
+	}
+
+	var cfg Config
+	expected := "user_id *model.Int32Filter, first_name *model.StringFilter, last_name *model.StringFilter, " +
+		"email *model.StringFilter, enabled *bool"
+	if actual := names(cfg.FilterColumns(table)); actual != expected {
+		t.Errorf("Expected %s but got %s", expected, actual)
+	}
+
+	cfg.Generator.StrictFilters = true
+	expected = "user_id *model.Int32Filter, first_name *model.StringFilter"
+	if actual := names(cfg.FilterColumns(table)); actual != expected {
+		t.Errorf("Expected the strict filters %s but got %s", expected, actual)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	table := Table{TableName: "user", TableSchema: "test_schema", Columns: []Column{
+		{ColumnName: "user_id", IsSequence: true, IsPrimaryKey: true},
+		{ColumnName: "first_name"},
+		{ColumnName: "email"},
+		{ColumnName: "enabled"},
+	}, Indexes: []Index{
+		{IndexName: "pk_user", IndexType: PrimaryKey, Columns: []string{"user_id"}},
+		{IndexName: "lookup_email", IndexType: Unique, Columns: []string{"email"}},
+		{IndexName: "lookup_name", IndexType: NonUnique, Columns: []string{"first_name"}},
+		{IndexName: "lookup_lower_email", IndexType: Unique, Columns: []string{"lower(email)"},
+			Expressions: []string{"lower(email)"}},
+		{IndexName: "unq_enabled_name", IndexType: Unique, Columns: []string{"first_name", "email"},
+			Predicate: "enabled"},
+	}, SkippedColumns: []string{"geog"}}
+
+	var cfg Config
+	if err := ReadFile(&cfg, writeConfig(t, validConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes := cfg.UpsertIndexes(table)
+	names := make([]string, len(indexes))
+	for i, index := range indexes {
+		names[i] = index.UpsertName()
+	}
+	if strings.Join(names, ", ") != "UpsertByEmail, UpsertByFirstNameAndEmail" {
+		t.Fatalf("Expected upserts by email and by first name and email but got %v", names)
+	}
+
+	columns := cfg.UpsertColumns(table, indexes[0])
+	if strings.Join(columns, ", ") != "first_name, enabled, geog" {
+		t.Errorf("Expected every inserted column but the key to be overwritten but got %v", columns)
+	}
+
+	expected := "ON CONFLICT (email) DO UPDATE SET first_name = EXCLUDED.first_name, enabled = EXCLUDED.enabled, " +
+		"geog = EXCLUDED.geog"
+	if clause := UpsertClause(indexes[0], columns); clause != expected {
+		t.Errorf("Expected %s but got %s", expected, clause)
+	}
+
+	cfg.Generator.Tables = []TableOverride{{Tablename: "test_schema.user", UpsertColumns: []string{}}}
+	columns = cfg.UpsertColumns(table, indexes[1])
+	expected = "ON CONFLICT (first_name, email) WHERE enabled DO UPDATE SET first_name = EXCLUDED.first_name"
+	if clause := UpsertClause(indexes[1], columns); clause != expected {
+		t.Errorf("Expected an upsert that keeps the row %s but got %s", expected, clause)
+	}
+
+	cfg.Generator.Tables[0].Operations = []string{"read", "create"}
+	if len(cfg.UpsertIndexes(table)) != 0 {
+		t.Error("Expected no upsert for a table that is not updated")
+	}
+
+	cfg.Generator.Tables[0].UpsertColumns = []string{"geog", "missing"}
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{table}}}}
+	expected = "generator.tables.0.upsert_columns.1: test_schema.user has no column missing"
+	if problems := cfg.ValidateDatabase(database); !strings.Contains(fmt.Sprint(problems), expected) {
+		t.Errorf("Expected %s but got %v", expected, problems)
+	}
+}
+
+func TestMaskColumns(t *testing.T) {
+	config := validConfig + `        update:
+          -
+            column: "geog"
+            data_type: "geography"
+            xform: "ST_SetSRID(ST_POINT($lon, $lat), 4326)::geography"
+  tables:
+    -
+      table: "test_schema.user"
+      columns:
+        -
+          column: "usr_nm"
+          field: "username"
+`
+	var cfg Config
+	if err := ReadFile(&cfg, writeConfig(t, config)); err != nil {
+		t.Fatal(err)
+	}
+
+	table := Table{TableName: "user", TableSchema: "test_schema", Columns: []Column{
+		{TableSchema: "test_schema", TableName: "user", ColumnName: "user_id", IsSequence: true, IsPrimaryKey: true},
+		{TableSchema: "test_schema", TableName: "user", ColumnName: "usr_nm"},
+		{TableSchema: "test_schema", TableName: "user", ColumnName: "geog"},
+	}}
+
+	expected := []MaskColumn{
+		{Column: "usr_nm", Value: "%s", Paths: []string{"username"}},
+		{Column: "geog", Value: "ST_SetSRID(ST_POINT(%s, %s), 4326)::geography", Paths: []string{"lon", "lat"}},
+	}
+	if columns := cfg.MaskColumns(table); !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected %v but got %v", expected, columns)
+	}
+}
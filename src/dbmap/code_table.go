@@ -0,0 +1,533 @@
+package dbmap
+
+import (
+	"fmt"
+	"github.com/iancoleman/strcase"
+	"regexp"
+	"strings"
+)
+
+// codeField A field of the record of a table, which is a column or the product of a select transform. A scalar is a
+// pointer in the message and a nullable type of database/sql in the nullable record, unless it is required, while a
+// slice, such as bytes or an array, is NULL when it is nil.
+type codeField struct {
+	Name      string
+	Column    Column
+	Virtual   bool
+	SelectSQL string
+	GoField   string
+	// The name of the field in the proto message, which is a path of a field mask
+	Path      string
+	Local     string
+	GoType    string
+	Import    string
+	Scalar    *goScalar
+	Composite *codeComposite
+	Required  bool
+	// An array is a repeated field, which is read and written through model.Array
+	Array bool
+	// The type of the column in SQL, which the temporary table of a COPY is created with
+	SQLType string
+}
+
+// codeWrite A column written by an INSERT or UPDATE. Value is its SQL with a %s for each of the Params, which is "%s"
+// for a plain column and the SQL of the transform, such as "ST_POINT(%s, %s)::geography", for a transformed one.
+type codeWrite struct {
+	Column string
+	Value  string
+	Params []*codeField
+}
+
+// codeLookup A lookup by the keys of an index whose name starts with lookup_
+type codeLookup struct {
+	Index  Index
+	Name   string
+	Params []*codeField
+}
+
+// codeMapping A query of generator.mapping, with its parameters numbered in the order they first appear
+type codeMapping struct {
+	Name     string
+	FuncName string
+	Query    string
+	Params   []codeParam
+	Rows     bool
+}
+
+type codeParam struct {
+	Name string
+	Type string
+}
+
+// codeTable What the code of a table is generated from: the names of its Go identifiers, its record and the columns
+// that each operation reads and writes
+type codeTable struct {
+	cfg    Config
+	table  Table
+	GoName string
+	Plural string
+	// The prefix of the unexported identifiers, such as userSelectStr
+	Prefix string
+	// The names of a parameter of a record and of many records, such as user and users
+	Local  string
+	Locals string
+	Noun   string
+	Nouns  string
+	Fields []*codeField
+	Keys   []*codeField
+	// The fields that the List function orders and pages by, which are nil when the table cannot be paged
+	Order []*codeField
+	// The version column, or nil when the table does not have one
+	Version *codeField
+	// The columns that the UpdateMask function can set
+	Masks []MaskColumn
+	// The fields that the Find function filters and sorts by
+	Filters []*codeField
+	// The indexes that an UpsertBy function is generated for
+	Upserts  []Index
+	Inserts  []codeWrite
+	Updates  []codeWrite
+	Lookups  []codeLookup
+	Mappings []codeMapping
+	// The composite types of the fields, where a type is ordered before a type that has an attribute of it
+	Composites codeComposites
+}
+
+// A RETURNING clause, which makes a write return rows
+var returningClause = regexp.MustCompile(`\bRETURNING\b`)
+
+// plural Returns the plural of a name in camel case, such as Users or Addresses. A name that already ends with s, such
+// as ProductParts, is its own plural.
+func plural(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "s"):
+		return name
+	case strings.HasSuffix(lower, "x") || strings.HasSuffix(lower, "ch") || strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
+
+// newCodeTable Plans the code of the table, returning an error naming what the generated code cannot read or write,
+// such as a column of a type that the proto message has no scalar for
+func newCodeTable(cfg Config, table Table) (*codeTable, error) {
+	t := &codeTable{cfg: cfg, table: table, GoName: cfg.GoName(table.TableSchema, table.TableName)}
+	t.Plural = plural(t.GoName)
+	t.Prefix = strcase.ToLowerCamel(t.GoName)
+	t.Local = goLocal(t.Prefix)
+	t.Locals = goLocal(strcase.ToLowerCamel(t.Plural))
+	if t.Locals == t.Local {
+		t.Locals += "List"
+	}
+	t.Noun = strings.ReplaceAll(strcase.ToSnake(t.GoName), "_", " ")
+	t.Nouns = strings.ReplaceAll(strcase.ToSnake(t.Plural), "_", " ")
+	t.Composites = codeComposites{Prefix: t.Prefix, Message: t.GoName}
+
+	if cfg.EmbedRelationships && len(table.Relations) > 0 {
+		return nil, fmt.Errorf("its foreign keys are embedded messages, which needs embed_relationships to be false")
+	}
+	if err := t.planFields(); err != nil {
+		return nil, err
+	}
+	if err := t.planWrites(); err != nil {
+		return nil, err
+	}
+	t.planKeys()
+	t.planLookups()
+	t.planMappings()
+	return t, nil
+}
+
+// transforms Returns the transforms of the table of the operation, which is select, insert or update
+func (t *codeTable) transforms(operation string) []Xform {
+	xforms := make([]Xform, 0)
+	for _, transform := range t.cfg.Generator.Transforms {
+		if !MatchTable(transform.Tablename, t.table.TableSchema, t.table.TableName) {
+			continue
+		}
+		switch operation {
+		case "select":
+			xforms = append(xforms, transform.Xforms.Select...)
+		case "insert":
+			xforms = append(xforms, transform.Xforms.Insert...)
+		case "update":
+			xforms = append(xforms, transform.Xforms.Update...)
+		}
+	}
+	return xforms
+}
+
+func (t *codeTable) planFields() error {
+	selects := make(map[string]Xform)
+	for _, xform := range t.transforms("select") {
+		if _, ok := selects[xform.Columnname]; !ok {
+			selects[xform.Columnname] = xform
+		}
+	}
+
+	version, hasVersion := t.cfg.VersionColumn(t.table)
+	for _, column := range t.table.Columns {
+		field, err := t.newField(column)
+		if err != nil {
+			return err
+		}
+		if xform, ok := selects[column.ColumnName]; ok {
+			field.SelectSQL = xform.Xform + " AS " + column.ColumnName
+		}
+		// A version is compared with the value that was read, so it has to be read into a scalar, such as a timestamp
+		// with a proto_type of string
+		if hasVersion && column.ColumnName == version.ColumnName && field.Scalar != nil && IsVersionType(column) {
+			t.Version = field
+		}
+		t.Fields = append(t.Fields, field)
+	}
+
+	// The products of the select transforms follow the columns, in the order they are configured
+	for _, column := range selectColumns(t.cfg, t.table) {
+		field, err := t.newField(column)
+		if err != nil {
+			return err
+		}
+		field.Virtual = true
+		field.SelectSQL = selects[column.ColumnName].Xform + " AS " + column.ColumnName
+		t.Fields = append(t.Fields, field)
+	}
+
+	if len(t.Fields) == 0 {
+		return fmt.Errorf("it has no columns")
+	}
+	return nil
+}
+
+// newField Returns the field of the column, with the type of the field of the proto message
+func (t *codeTable) newField(column Column) (*codeField, error) {
+	field := &codeField{Name: column.ColumnName, Column: column, SelectSQL: column.ColumnName,
+		GoField: t.cfg.GoFieldName(column), Path: t.cfg.FieldName(column),
+		Local: goLocal(strcase.ToLowerCamel(t.cfg.FieldName(column))), SQLType: column.UdtName}
+	if column.DataType == "ARRAY" {
+		field.Array = true
+		field.SQLType = column.ElementType + "[]"
+	}
+
+	// A type that is not a proto scalar is a Go type of another package, given with its import path
+	if goType := t.cfg.GoType(column); goType != "" {
+		i := strings.LastIndex(goType, "/")
+		if i < 0 && strings.Contains(goType, ".") {
+			return nil, fmt.Errorf("the go_type %s of %s is not qualified by its import path", goType,
+				column.ColumnName)
+		}
+		if i >= 0 {
+			field.Import = goType[:strings.LastIndex(goType, ".")]
+			field.GoType = goType[i+1:]
+		} else {
+			field.GoType = goType
+		}
+		return field, nil
+	}
+
+	if column.Composite != nil && field.Array {
+		return nil, fmt.Errorf("%s is an array of the composite type %s", column.ColumnName,
+			column.Composite.TypeName)
+	} else if column.Composite != nil {
+		composite, err := t.Composites.composite(t.cfg, column.Composite)
+		if err != nil {
+			return nil, err
+		}
+		field.Composite = composite
+		field.GoType = "*" + composite.Message
+		return field, nil
+	} else if column.ArrayDims > 1 {
+		return nil, fmt.Errorf("%s is an array of %d dimensions", column.ColumnName, column.ArrayDims)
+	}
+
+	// An array is read into a repeated field of the type of its elements
+	sType := column.UdtName
+	if field.Array {
+		sType = column.ElementType
+	}
+	protoType, ok := sqlToProtoType(sType)
+	if override := t.cfg.ColumnOverride(column.TableSchema, column.TableName, column.ColumnName).ProtoType; override != "" {
+		protoType, ok = override, true
+	} else if sType == "date" || sType == "money" || strings.HasPrefix(sType, "bit") || sType == "varbit" ||
+		(strings.HasPrefix(sType, "time") && !strings.HasPrefix(sType, "timestamp")) {
+		ok = false
+	}
+
+	if protoType == "bytes" && ok {
+		field.GoType = "[]byte"
+		if field.Array {
+			field.GoType = "[][]byte"
+		}
+		return field, nil
+	}
+	scalar, known := goScalars[protoType]
+	if !ok || !known {
+		return nil, fmt.Errorf("%s is of the type %s, which cannot be read into a proto scalar", column.ColumnName,
+			sType)
+	}
+	if field.Array {
+		field.GoType = "[]" + scalar.Type
+	} else {
+		field.GoType = "*" + scalar.Type
+		field.Scalar = &scalar
+	}
+	return field, nil
+}
+
+// field Returns the field of the column or select transform
+func (t *codeTable) field(name string) *codeField {
+	for _, field := range t.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// writes Returns the columns written by the operation, insert or update. Every column that is read is written, except
+// for those assigned by a sequence, the version column and, for an update, the primary key, followed by the columns
+// of the transforms of the operation.
+func (t *codeTable) writes(operation string) ([]codeWrite, error) {
+	xforms := t.transforms(operation)
+	transformed := make(map[string]bool)
+	for _, xform := range xforms {
+		transformed[xform.Columnname] = true
+	}
+
+	writes := make([]codeWrite, 0)
+	for _, field := range t.Fields {
+		if field.Virtual || field.Column.IsSequence || field == t.Version || transformed[field.Name] ||
+			(operation == "update" && field.Column.IsPrimaryKey) {
+			continue
+		}
+		writes = append(writes, codeWrite{Column: field.Name, Value: "%s", Params: []*codeField{field}})
+	}
+
+	for _, xform := range xforms {
+		params := make([]*codeField, 0)
+		var missing string
+		value := xformReference.ReplaceAllStringFunc(strings.ReplaceAll(xform.Xform, "%", "%%"),
+			func(reference string) string {
+				field := t.field(reference[1:])
+				if field == nil {
+					missing = reference
+				}
+				params = append(params, field)
+				return "%s"
+			})
+		if missing != "" {
+			return nil, fmt.Errorf("the %s transform of %s reads %s, which is not a column that is read", operation,
+				xform.Columnname, missing)
+		}
+		writes = append(writes, codeWrite{Column: xform.Columnname, Value: value, Params: params})
+	}
+	return writes, nil
+}
+
+func (t *codeTable) planWrites() error {
+	var err error
+	if t.cfg.GeneratesOperation(t.table.TableSchema, t.table.TableName, "create") {
+		if t.Inserts, err = t.writes("insert"); err != nil {
+			return err
+		}
+	}
+	if t.cfg.GeneratesOperation(t.table.TableSchema, t.table.TableName, "update") {
+		if t.Updates, err = t.writes("update"); err != nil {
+			return err
+		}
+	}
+
+	// A NOT NULL column that is written has to be set, so it is written from the value of the field rather than
+	// through a nullable type
+	written := make(map[*codeField]bool)
+	for _, write := range append(append([]codeWrite{}, t.Inserts...), t.Updates...) {
+		for _, param := range write.Params {
+			written[param] = true
+		}
+	}
+	for _, field := range t.Fields {
+		if (written[field] || (len(t.Updates) > 0 && field.Column.IsPrimaryKey)) && field.Scalar != nil &&
+			!field.Virtual && !field.Column.IsNullable && !field.Column.IsSequence && field != t.Version {
+			field.Required = true
+		}
+	}
+	return nil
+}
+
+// planKeys Finds the primary key, which the single row operations are by. A table without one is not updated
+func (t *codeTable) planKeys() {
+	names := make([]string, 0)
+	for _, index := range t.table.Indexes {
+		if index.IndexType == PrimaryKey {
+			names = index.Columns
+		}
+	}
+	if len(names) == 0 {
+		for _, column := range t.table.Columns {
+			if column.IsPrimaryKey {
+				names = append(names, column.ColumnName)
+			}
+		}
+	}
+	t.Keys = t.fields(names)
+	if t.Keys == nil {
+		t.Updates = nil
+	}
+	// A column is masked by the fields it is written from, which have to be fields of the record
+	if len(t.Updates) > 0 {
+		for _, mask := range t.cfg.MaskColumns(t.table) {
+			if t.pathFields(mask.Paths) != nil {
+				t.Masks = append(t.Masks, mask)
+			}
+		}
+	}
+
+	// A page is continued after the key of its last row, which has to be a scalar
+	if order, err := t.cfg.ListOrder(t.table); err == nil {
+		t.Order = t.fields(order)
+		for _, field := range t.Order {
+			if field.Scalar == nil {
+				t.Order = nil
+				break
+			}
+		}
+	}
+
+	for _, column := range t.cfg.FilterColumns(t.table) {
+		if field := t.field(column.ColumnName); field != nil {
+			t.Filters = append(t.Filters, field)
+		}
+	}
+	if len(t.Inserts) > 0 && len(t.Updates) > 0 {
+		t.Upserts = t.cfg.UpsertIndexes(t.table)
+	}
+}
+
+// pathFields Returns the fields of the proto paths, or nil when any of them is not a field of the record
+func (t *codeTable) pathFields(paths []string) []*codeField {
+	fields := make([]*codeField, 0, len(paths))
+	for _, path := range paths {
+		var found *codeField
+		for _, field := range t.Fields {
+			if field.Path == path {
+				found = field
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		fields = append(fields, found)
+	}
+	return fields
+}
+
+// fields Returns the fields of the columns, or nil when any of them is not read
+func (t *codeTable) fields(names []string) []*codeField {
+	fields := make([]*codeField, 0, len(names))
+	for _, name := range names {
+		field := t.field(name)
+		if field == nil || field.Virtual {
+			return nil
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// planLookups Finds the indexes named lookup_ that a lookup is generated for. A key that is an expression is looked
+// up by the column it references, e.g. lower(email) by the email, so one that references more than one column cannot
+// be looked up.
+func (t *codeTable) planLookups() {
+	if !t.cfg.Generator.IndexedLookups {
+		return
+	}
+
+	for _, index := range t.table.Indexes {
+		if !strings.HasPrefix(index.IndexName, "lookup_") || !index.IsEqualityLookup() || len(index.Columns) == 0 {
+			continue
+		}
+
+		params := make([]*codeField, 0, len(index.Columns))
+		for _, key := range index.Columns {
+			name := key
+			if index.IsExpression(key) {
+				name = expressionColumn(key, t.table)
+			}
+			field := t.field(name)
+			if field == nil || field.Virtual || (field.Scalar == nil && field.GoType != "[]byte") {
+				params = nil
+				break
+			}
+			params = append(params, field)
+		}
+		if params == nil {
+			t.cfg.Logf("[warning] Not generating a lookup by the index %s of %s.%s, whose keys are not columns "+
+				"that are read\n", index.IndexName, t.table.TableSchema, t.table.TableName)
+			continue
+		}
+		t.Lookups = append(t.Lookups, codeLookup{Index: index, Name: strcase.ToCamel(strings.TrimPrefix(
+			index.IndexName, "lookup_")), Params: params})
+	}
+}
+
+// expressionColumn Returns the column that the expression references, or an empty string when it references none or
+// more than one, so that it cannot be bound to a parameter
+func expressionColumn(expression string, table Table) string {
+	if _, ok := bindExpression(expression, table, "$"); !ok {
+		return ""
+	}
+
+	columns := make(map[string]bool)
+	for _, column := range table.Columns {
+		columns[column.ColumnName] = true
+	}
+	for _, token := range expressionTokens.FindAllStringIndex(expression, -1) {
+		if name, ok := columnReference(expression, token, columns); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// planMappings Numbers the parameters of the queries of generator.mapping, where a parameter that appears more than
+// once, such as $lon:float64 in both the filter and the sort, is passed once
+func (t *codeTable) planMappings() {
+	for _, mapping := range t.cfg.Generator.Mapping {
+		if !MatchTable(mapping.Tablename, t.table.TableSchema, t.table.TableName) {
+			continue
+		}
+
+		for _, query := range mapping.Queries {
+			m := codeMapping{Name: query.Name, FuncName: strcase.ToCamel(query.Name)}
+			numbers := make(map[string]int)
+			m.Query = queryParameter.ReplaceAllStringFunc(query.Query, func(parameter string) string {
+				match := queryParameter.FindStringSubmatch(parameter)
+				if _, ok := numbers[match[1]]; !ok {
+					goType := match[2]
+					if goType == "bytes" {
+						goType = "[]byte"
+					} else if goType == "time" {
+						goType = "time.Time"
+					}
+					m.Params = append(m.Params, codeParam{Name: match[1], Type: goType})
+					numbers[match[1]] = len(m.Params)
+				}
+				return fmt.Sprintf("$%d", numbers[match[1]])
+			})
+			for i, param := range m.Params {
+				m.Params[i].Name = goLocal(param.Name)
+			}
+
+			statement := strings.ToUpper(strings.TrimSpace(query.Query))
+			m.Rows = strings.HasPrefix(statement, "SELECT") || strings.HasPrefix(statement, "WITH") ||
+				strings.HasPrefix(statement, "VALUES") || returningClause.MatchString(statement)
+			t.Mappings = append(t.Mappings, m)
+		}
+	}
+}
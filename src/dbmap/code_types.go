@@ -0,0 +1,176 @@
+package dbmap
+
+import (
+	"fmt"
+	"github.com/iancoleman/strcase"
+	"go/token"
+	"io"
+	"strings"
+)
+
+// goScalar How a scalar field of a proto2 message, which is a pointer, is read and written through the nullable type
+// of database/sql
+type goScalar struct {
+	Type    string
+	Null    string
+	SetNull string
+	Set     string
+}
+
+// The scalars of the proto messages that the generated code can read and write, by their proto type
+var goScalars = map[string]goScalar{
+	"int32":  {"int32", "sql.NullInt32", "model.SetNullInt32", "model.SetInt32"},
+	"int64":  {"int64", "sql.NullInt64", "model.SetNullInt64", "model.SetInt64"},
+	"double": {"float64", "sql.NullFloat64", "model.SetNullFloat64", "model.SetFloat64"},
+	"string": {"string", "sql.NullString", "model.SetNullString", "model.SetString"},
+	"bool":   {"bool", "sql.NullBool", "model.SetNullBool", "model.SetBool"},
+}
+
+// codeComposite A composite type, which the generated code scans and writes through a struct of the nullable types of
+// its attributes. The struct is converted to and from the message of the type, which is nested in the message of the
+// table.
+type codeComposite struct {
+	Type    *CompositeType
+	Name    string
+	Message string
+	Fields  []codeAttribute
+}
+
+// codeAttribute An attribute of a composite type, which is either a nullable scalar or another composite type
+type codeAttribute struct {
+	Local     string
+	GoField   string
+	Scalar    *goScalar
+	Composite *codeComposite
+}
+
+// codeComposites The composite types that the code of a table reads. They are named after the table, such as
+// userAddressT, since the code of every table of a schema is in the same package, and ordered so that a type is
+// declared before a type that has an attribute of it
+type codeComposites struct {
+	Prefix  string
+	Message string
+	Types   []*codeComposite
+}
+
+// The names of the parameters and variables of the generated functions, which a parameter named after a column is not
+// to shadow
+var codeLocals = map[string]bool{
+	"ctx": true, "db": true, "m": true, "err": true, "rows": true, "returning": true, "nullable": true,
+	"limit": true, "cursor": true, "next": true, "after": true, "filter": true, "sort": true, "offset": true,
+	"results": true, "result": true, "where": true, "orderBy": true, "query": true, "count": true, "exact": true,
+	"exists": true, "model": true, "sql": true, "fmt": true, "strings": true, "driver": true, "context": true,
+	"errors": true, "time": true, "values": true, "args": true, "i": true, "chunkSize": true, "start": true,
+	"end": true, "tx": true, "row": true, "pq": true, "set": true, "fields": true, "field": true, "mask": true,
+	"fieldmaskpb": true,
+}
+
+// goLocal Returns the name for a parameter, a variable or a field of a struct, which gets a trailing underscore when it
+// is a keyword or would shadow one of the names of the generated functions
+func goLocal(name string) string {
+	if codeLocals[name] || token.IsKeyword(name) {
+		return name + "_"
+	}
+	return name
+}
+
+// composite Returns the composite type, planning it along with the composite types of its attributes the first time
+// that it is read. The fields of an attribute are named and typed like those of its nested message.
+func (c *codeComposites) composite(cfg Config, compositeType *CompositeType) (*codeComposite, error) {
+	for _, composite := range c.Types {
+		if composite.Type == compositeType {
+			return composite, nil
+		}
+	}
+
+	name := strcase.ToCamel(compositeType.TypeName)
+	composite := &codeComposite{Type: compositeType, Name: c.Prefix + name, Message: c.Message + "_" + name}
+	for _, attribute := range compositeType.Attributes {
+		field := codeAttribute{Local: goLocal(strcase.ToLowerCamel(attribute.ColumnName)),
+			GoField: cfg.GoFieldName(attribute)}
+		fieldType := protoType(attribute)
+		override := cfg.ColumnOverride(attribute.TableSchema, attribute.TableName, attribute.ColumnName)
+		if override.ProtoType != "" {
+			fieldType = override.ProtoType
+		}
+		if attribute.Composite != nil && attribute.DataType != "ARRAY" {
+			nested, err := c.composite(cfg, attribute.Composite)
+			if err != nil {
+				return nil, err
+			}
+			field.Composite = nested
+		} else if scalar, ok := goScalars[fieldType]; ok && attribute.DataType != "ARRAY" {
+			field.Scalar = &scalar
+		} else {
+			return nil, fmt.Errorf("the attribute %s of the composite type %s is of the type %s, which is not a "+
+				"scalar", attribute.ColumnName, compositeType.TypeName, attribute.UdtName)
+		}
+		composite.Fields = append(composite.Fields, field)
+	}
+	c.Types = append(c.Types, composite)
+	return composite, nil
+}
+
+// writeComposite Writes the struct that a composite type is scanned into and written from, which converts the text
+// of its attributes through model.Composite, along with its conversions to and from the message of the type. A nil
+// message is NULL.
+func writeComposite(w io.Writer, composite *codeComposite) {
+	scans := make([]string, len(composite.Fields))
+	values := make([]string, len(composite.Fields))
+	for i, field := range composite.Fields {
+		scans[i] = "&c." + field.Local
+		values[i] = "c." + field.Local
+	}
+	name := strcase.ToCamel(composite.Name)
+
+	_, _ = fmt.Fprintf(w, "\n// %s The attributes of the composite type %s.%s, which is NULL when it is not Valid\n",
+		composite.Name, composite.Type.TypeSchema, composite.Type.TypeName)
+	_, _ = fmt.Fprintf(w, "type %s struct {\n", composite.Name)
+	for _, field := range composite.Fields {
+		if field.Composite != nil {
+			_, _ = fmt.Fprintf(w, "%s %s\n", field.Local, field.Composite.Name)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s %s\n", field.Local, field.Scalar.Null)
+		}
+	}
+	_, _ = fmt.Fprint(w, "Valid bool\n}\n")
+
+	_, _ = fmt.Fprintf(w, "\nfunc (c *%s) Scan(src interface{}) error {\n", composite.Name)
+	_, _ = fmt.Fprint(w, "var composite model.Composite\n")
+	_, _ = fmt.Fprint(w, "if err := composite.Scan(src); err != nil || !composite.Valid {\n")
+	_, _ = fmt.Fprintf(w, "*c = %s{}\nreturn err\n}\n", composite.Name)
+	_, _ = fmt.Fprint(w, "c.Valid = true\n")
+	_, _ = fmt.Fprintf(w, "return composite.ScanFields(%s)\n}\n", strings.Join(scans, ", "))
+
+	_, _ = fmt.Fprintf(w, "\nfunc (c %s) Value() (driver.Value, error) {\n", composite.Name)
+	_, _ = fmt.Fprint(w, "if !c.Valid {\nreturn nil, nil\n}\n")
+	_, _ = fmt.Fprintf(w, "composite, err := model.NewComposite(%s)\n", strings.Join(values, ", "))
+	_, _ = fmt.Fprint(w, "if err != nil {\nreturn nil, err\n}\n")
+	_, _ = fmt.Fprint(w, "return composite.Value()\n}\n")
+
+	_, _ = fmt.Fprintf(w, "\nfunc to%s(m *%s) %s {\n", name, composite.Message, composite.Name)
+	_, _ = fmt.Fprintf(w, "if m == nil {\nreturn %s{}\n}\n", composite.Name)
+	_, _ = fmt.Fprintf(w, "return %s{\n", composite.Name)
+	for _, field := range composite.Fields {
+		if field.Composite != nil {
+			_, _ = fmt.Fprintf(w, "%s: to%s(m.%s),\n", field.Local, strcase.ToCamel(field.Composite.Name),
+				field.GoField)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s: %s(m.%s),\n", field.Local, field.Scalar.SetNull, field.GoField)
+		}
+	}
+	_, _ = fmt.Fprint(w, "Valid: true,\n}\n}\n")
+
+	_, _ = fmt.Fprintf(w, "\nfunc from%s(c %s) *%s {\n", name, composite.Name, composite.Message)
+	_, _ = fmt.Fprint(w, "if !c.Valid {\nreturn nil\n}\n")
+	_, _ = fmt.Fprintf(w, "return &%s{\n", composite.Message)
+	for _, field := range composite.Fields {
+		if field.Composite != nil {
+			_, _ = fmt.Fprintf(w, "%s: from%s(c.%s),\n", field.GoField, strcase.ToCamel(field.Composite.Name),
+				field.Local)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s: %s(c.%s),\n", field.GoField, field.Scalar.Set, field.Local)
+		}
+	}
+	_, _ = fmt.Fprint(w, "}\n}\n")
+}
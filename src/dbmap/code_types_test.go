@@ -0,0 +1,69 @@
+package dbmap
+
+import (
+	"go/format"
+	"strings"
+	"testing"
+)
+
+func TestWriteComposite(t *testing.T) {
+	attribute := func(typeName string, name string, udtName string) Column {
+		return Column{TableSchema: "shop", TableName: typeName, ColumnName: name, UdtName: udtName, IsNullable: true}
+	}
+	point := &CompositeType{TypeSchema: "shop", TypeName: "point_t", Attributes: []Column{
+		attribute("point_t", "lat", "float8"), attribute("point_t", "lon", "float8"),
+	}}
+	location := attribute("address_t", "location", "point_t")
+	location.DataType, location.Composite = "USER-DEFINED", point
+	address := &CompositeType{TypeSchema: "shop", TypeName: "address_t", Attributes: []Column{
+		attribute("address_t", "street", "text"), attribute("address_t", "zip", "int4"),
+		attribute("address_t", "type", "text"), location,
+	}}
+
+	var cfg Config
+	cfg.Generator.Tables = []TableOverride{{Tablename: "shop.address_t",
+		Columns: []ColumnOverride{{Columnname: "zip", GoName: "PostalCode"}}}}
+	composites := codeComposites{Prefix: "customer", Message: "Customer"}
+	if _, err := composites.composite(cfg, address); err != nil {
+		t.Fatal(err)
+	}
+	if len(composites.Types) != 2 || composites.Types[0].Type != point {
+		t.Fatal("Expected point_t to be declared before address_t")
+	}
+
+	var b strings.Builder
+	b.WriteString("package shop\n")
+	for _, composite := range composites.Types {
+		writeComposite(&b, composite)
+	}
+	code, err := format.Source([]byte(b.String()))
+	if err != nil {
+		t.Fatalf("Expected the code to be valid, got %s\n%s", err, b.String())
+	}
+
+	expected := []string{
+		"type customerPointT struct {\n\tlat   sql.NullFloat64\n\tlon   sql.NullFloat64\n\tValid bool\n}",
+		"\tstreet   sql.NullString\n\tzip      sql.NullInt32\n\ttype_    sql.NullString\n\tlocation customerPointT\n",
+		"return composite.ScanFields(&c.street, &c.zip, &c.type_, &c.location)",
+		"composite, err := model.NewComposite(c.street, c.zip, c.type_, c.location)",
+		"func toCustomerAddressT(m *Customer_AddressT) customerAddressT {",
+		"location: toCustomerPointT(m.Location),",
+		"PostalCode: model.SetInt32(c.zip),",
+		"Location:   fromCustomerPointT(c.location),",
+		"zip:      model.SetNullInt32(m.PostalCode),",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(code), e) {
+			t.Errorf("Expected the code to contain %q but got\n%s", e, code)
+		}
+	}
+
+	// An array cannot be converted from the text of an attribute
+	lines := attribute("address_t", "lines", "text[]")
+	lines.DataType = "ARRAY"
+	address.Attributes = append(address.Attributes, lines)
+	composites = codeComposites{Prefix: "customer", Message: "Customer"}
+	if _, err := composites.composite(cfg, address); err == nil {
+		t.Error("Expected an array attribute to not be read")
+	}
+}
@@ -0,0 +1,1042 @@
+package dbmap
+
+import (
+	"fmt"
+	"github.com/iancoleman/strcase"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The import paths of the packages that the generated code can reference, by the name it references them with
+var codeImports = map[string]string{
+	"context":     "context",
+	"sql":         "database/sql",
+	"driver":      "database/sql/driver",
+	"fmt":         "fmt",
+	"model":       "github.com/bryanhughes/go_dbmap/src/model",
+	"pq":          "github.com/lib/pq",
+	"fieldmaskpb": "google.golang.org/protobuf/types/known/fieldmaskpb",
+	"strings":     "strings",
+	"time":        "time",
+}
+
+// codeWriter Writes the code of a table, which is formatted once it is complete
+type codeWriter struct {
+	t *codeTable
+	b strings.Builder
+}
+
+func (w *codeWriter) p(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(&w.b, format, args...)
+}
+
+// writeCode Returns the formatted code of the table, with the imports of the packages it references
+func writeCode(t *codeTable) ([]byte, error) {
+	w := &codeWriter{t: t}
+	w.writeRecord()
+	w.writeRead()
+	w.writeCreate()
+	w.writeUpdate()
+	w.writeDelete()
+	w.writeCreateMany()
+	w.writeUpserts()
+	w.writeList()
+	w.writeCount()
+	w.writeFind()
+	w.writeLookups()
+	w.writeMappings()
+
+	// The package of a go_type is imported by its path
+	extra := make([]string, 0)
+	for _, field := range t.Fields {
+		if field.Import != "" {
+			extra = append(extra, field.Import)
+		}
+	}
+	imports, err := codeImportsOf(w.b.String(), extra...)
+	if err != nil {
+		return nil, err
+	}
+
+	var file strings.Builder
+	_, _ = fmt.Fprintf(&file, "// Code generated by go_dbmap from the %s.%s table. DO NOT EDIT.\n\n", t.table.TableSchema,
+		t.table.TableName)
+	_, _ = fmt.Fprintf(&file, "package %s\n\n", codePackage(t.table.TableSchema))
+	_, _ = fmt.Fprint(&file, "import (\n")
+	for _, path := range imports {
+		_, _ = fmt.Fprintf(&file, "\t%s\n", strconv.Quote(path))
+	}
+	_, _ = fmt.Fprint(&file, ")\n")
+	_, _ = fmt.Fprint(&file, w.b.String())
+	return format.Source([]byte(file.String()))
+}
+
+// codePackage Returns the name of the Go package of the schema, which is the package of the proto messages compiled
+// from the protos of the schema
+func codePackage(schema string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(schema))
+}
+
+// codeImportsOf Returns the sorted import paths of the packages that the code references, which are the qualifiers
+// that do not resolve to a declaration of the code itself, and of the extra packages
+func codeImportsOf(code string, extra ...string) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package code\n"+code, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool)
+	for _, path := range extra {
+		referenced[path] = true
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok && ident.Obj == nil && codeImports[ident.Name] != "" {
+				referenced[codeImports[ident.Name]] = true
+			}
+		}
+		return true
+	})
+
+	imports := make([]string, 0, len(referenced))
+	for path := range referenced {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	return imports, nil
+}
+
+// doc Writes a comment after a blank line, wrapped at 120 columns
+func (w *codeWriter) doc(format string, args ...interface{}) {
+	w.p("\n")
+	line := "//"
+	for _, word := range strings.Fields(fmt.Sprintf(format, args...)) {
+		if len(line)+1+len(word) > 120 && line != "//" {
+			w.p("%s\n", line)
+			line = "//"
+		}
+		line += " " + word
+	}
+	w.p("%s\n", line)
+}
+
+// name Returns the name of an unexported identifier of the table, such as userSelectStr
+func (w *codeWriter) name(suffix string) string {
+	return w.t.Prefix + suffix
+}
+
+func (w *codeWriter) writeRecord() {
+	t := w.t
+	columns := make([]string, len(t.Fields))
+	for i, field := range t.Fields {
+		columns[i] = field.SelectSQL
+	}
+
+	w.doc("The table of the errors returned by every function")
+	w.p("const %s = %s\n\n", w.name("Table"), strconv.Quote(t.table.TableSchema+"."+t.table.TableName))
+	w.p("// The columns that every function reads the %s from\n", t.Noun)
+	w.p("const %s = %s\n", w.name("Columns"), strconv.Quote(strings.Join(columns, ", ")))
+	for _, composite := range t.Composites.Types {
+		writeComposite(&w.b, composite)
+	}
+
+	w.doc("nullable%s The columns of the %s as they are scanned, where a column that can be NULL is read into a "+
+		"nullable type", t.GoName, t.Noun)
+	w.p("type nullable%s struct {\n", t.GoName)
+	for _, field := range t.Fields {
+		w.p("%s %s\n", field.Local, nullableType(field))
+	}
+	w.p("}\n")
+
+	w.p("\nfunc toNullable%s(m *%s) nullable%s {\n", t.GoName, t.GoName, t.GoName)
+	w.p("return nullable%s{\n", t.GoName)
+	for _, field := range t.Fields {
+		switch {
+		case field.Required:
+			w.p("%s: *m.%s,\n", field.Local, field.GoField)
+		case field.Scalar != nil:
+			w.p("%s: %s(m.%s),\n", field.Local, field.Scalar.SetNull, field.GoField)
+		case field.Composite != nil:
+			w.p("%s: to%s(m.%s),\n", field.Local, strcase.ToCamel(field.Composite.Name), field.GoField)
+		default:
+			w.p("%s: m.%s,\n", field.Local, field.GoField)
+		}
+	}
+	w.p("}\n}\n")
+
+	w.p("\nfunc fromNullable%s(m *%s, n nullable%s) {\n", t.GoName, t.GoName, t.GoName)
+	for _, field := range t.Fields {
+		switch {
+		case field.Required:
+			w.p("m.%s = &n.%s\n", field.GoField, field.Local)
+		case field.Scalar != nil:
+			w.p("m.%s = %s(n.%s)\n", field.GoField, field.Scalar.Set, field.Local)
+		case field.Composite != nil:
+			w.p("m.%s = from%s(n.%s)\n", field.GoField, strcase.ToCamel(field.Composite.Name), field.Local)
+		default:
+			w.p("m.%s = n.%s\n", field.GoField, field.Local)
+		}
+	}
+	w.p("}\n")
+
+	if !w.validates() {
+		return
+	}
+	w.doc("validate%sNotNulls Returns a *model.ErrNotNullViolation for the first field of the %s that is nil while "+
+		"its column is NOT NULL", t.GoName, t.Noun)
+	w.p("func validate%sNotNulls(m *%s) error {\n", t.GoName, t.GoName)
+	for _, field := range t.Fields {
+		if field.Required {
+			w.p("if m.%s == nil {\n", field.GoField)
+			w.p("return &model.ErrNotNullViolation{Column: %s}\n", strconv.Quote(field.Name))
+			w.p("}\n")
+		}
+	}
+	w.p("return nil\n}\n")
+}
+
+// nullableType Returns the type of the field in the nullable record
+func nullableType(field *codeField) string {
+	if field.Composite != nil {
+		return field.Composite.Name
+	} else if field.Scalar == nil {
+		return field.GoType
+	} else if field.Required {
+		return field.Scalar.Type
+	}
+	return field.Scalar.Null
+}
+
+// validates Returns true if the table has a field that has to be set to write the record
+func (w *codeWriter) validates() bool {
+	for _, field := range w.t.Fields {
+		if field.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// validate Writes the check of the NOT NULL fields of the record, returning from a function whose other results are
+// the zero values
+func (w *codeWriter) validate(record string, op string, zero string) {
+	if w.validates() {
+		w.p("if err := validate%sNotNulls(%s); err != nil {\n", w.t.GoName, record)
+		w.p("return %smodel.WrapError(%s, %s, err)\n", zero, w.name("Table"), strconv.Quote(op))
+		w.p("}\n")
+	}
+}
+
+// scanArgs Returns the destinations of a row in the nullable record
+func (w *codeWriter) scanArgs(record string) string {
+	args := make([]string, len(w.t.Fields))
+	for i, field := range w.t.Fields {
+		if field.Array {
+			args[i] = "model.Array(&" + record + "." + field.Local + ")"
+		} else {
+			args[i] = "&" + record + "." + field.Local
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+// writeArgs Returns the parameters of the keys, the writes and then the extra fields, from the nullable record
+func writeArgs(record string, keys []*codeField, writes []codeWrite, extra ...*codeField) string {
+	args := make([]string, 0)
+	for _, field := range keys {
+		args = append(args, nullableArg(record, field))
+	}
+	for _, write := range writes {
+		for _, field := range write.Params {
+			args = append(args, nullableArg(record, field))
+		}
+	}
+	for _, field := range extra {
+		args = append(args, nullableArg(record, field))
+	}
+	return strings.Join(args, ", ")
+}
+
+// nullableArg Returns the parameter of the field of the nullable record
+func nullableArg(record string, field *codeField) string {
+	if field.Array {
+		return "model.Array(" + record + "." + field.Local + ")"
+	}
+	return record + "." + field.Local
+}
+
+// messageArg Returns the parameter of the field of the message, where a nil field is NULL
+func messageArg(field *codeField) string {
+	switch {
+	case field.Array:
+		return "model.Array(m." + field.GoField + ")"
+	case field.Scalar != nil:
+		return field.Scalar.SetNull + "(m." + field.GoField + ")"
+	case field.Composite != nil:
+		return "to" + strcase.ToCamel(field.Composite.Name) + "(m." + field.GoField + ")"
+	}
+	return "m." + field.GoField
+}
+
+// writeParams Returns the number of parameters of the writes
+func writeParams(writes []codeWrite) int {
+	count := 0
+	for _, write := range writes {
+		count += len(write.Params)
+	}
+	return count
+}
+
+// writeValues Returns the values of the writes, with the parameters numbered from after offset
+func writeValues(writes []codeWrite, offset int) []string {
+	values := make([]string, len(writes))
+	for i, write := range writes {
+		params := make([]interface{}, len(write.Params))
+		for j := range write.Params {
+			offset++
+			params[j] = "$" + strconv.Itoa(offset)
+		}
+		values[i] = fmt.Sprintf(write.Value, params...)
+	}
+	return values
+}
+
+func writeColumns(writes []codeWrite) string {
+	columns := make([]string, len(writes))
+	for i, write := range writes {
+		columns[i] = write.Column
+	}
+	return strings.Join(columns, ", ")
+}
+
+// keyWhere Returns the condition on the primary key, with the parameters starting after offset
+func keyWhere(keys []*codeField, offset int) string {
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		conditions[i] = key.Name + " = $" + strconv.Itoa(offset+i+1)
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// keyArgs Returns the parameters of the primary key of the message
+func (w *codeWriter) keyArgs() string {
+	args := make([]string, len(w.t.Keys))
+	for i, key := range w.t.Keys {
+		if key.Array {
+			args[i] = "model.Array(m." + key.GoField + ")"
+		} else {
+			args[i] = "m." + key.GoField
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+// readOne Writes the query of a single row and the scan of the row into the message m, within a function that only
+// returns an error. The noRow expression is the error when there is no row.
+func (w *codeWriter) readOne(op string, query string, args string, noRow string) {
+	t := w.t
+	table := w.name("Table")
+	if args != "" {
+		args = ", " + args
+	}
+	w.p("rows, err := db.QueryContext(ctx, %s%s)\n", query, args)
+	w.p("if err != nil {\nreturn model.WrapError(%s, %s, err)\n}\n", table, strconv.Quote(op))
+	w.p("defer rows.Close()\n\n")
+	w.p("var returning = nullable%s{}\n", t.GoName)
+	w.p("if !rows.Next() {\nreturn model.WrapError(%s, %s, %s)\n}\n", table, strconv.Quote(op), noRow)
+	w.p("if err := rows.Scan(%s); err != nil {\n", w.scanArgs("returning"))
+	w.p("return model.WrapError(%s, %s, err)\n}\n\n", table, strconv.Quote(op))
+	w.p("fromNullable%s(m, returning)\nreturn nil\n}\n", t.GoName)
+}
+
+// readMany Writes the query of the rows into the results, within a function whose first result is the records. The
+// results are allocated with the capacity, which is the limit of the query
+func (w *codeWriter) readMany(op string, query string, args string, capacity string) {
+	t := w.t
+	fail := fmt.Sprintf("return []%s{}, model.WrapError(%s, %s, err)\n", t.GoName, w.name("Table"), strconv.Quote(op))
+	w.p("rows, err := db.QueryContext(ctx, %s, %s)\n", query, args)
+	w.p("if err != nil {\n%s}\n", fail)
+	w.p("defer rows.Close()\n\n")
+	w.p("results := make([]%s, 0, %s)\n", t.GoName, capacity)
+	w.p("var returning = nullable%s{}\n", t.GoName)
+	w.p("for rows.Next() {\n")
+	w.p("result := %s{}\n", t.GoName)
+	w.p("if err := rows.Scan(%s); err != nil {\n%s}\n\n", w.scanArgs("returning"), fail)
+	w.p("fromNullable%s(&result, returning)\nresults = append(results, result)\n}\n\n", t.GoName)
+	w.p("if err := rows.Err(); err != nil {\n%s}\n", fail)
+	w.p("return results, nil\n}\n")
+}
+
+// keyParams Returns the parameters of a function that are the fields of the keys. A scalar is passed by value rather
+// than as the pointer of its proto2 field, since a key is never NULL.
+func keyParams(keys []*codeField) string {
+	params := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Scalar != nil {
+			params[i] = key.Local + " " + key.Scalar.Type
+		} else {
+			params[i] = key.Local + " " + key.GoType
+		}
+	}
+	return strings.Join(params, ", ")
+}
+
+func keyNames(keys []*codeField) []string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.Name
+	}
+	return names
+}
+
+// keyLocals Returns the arguments of the parameters of keyParams
+func keyLocals(keys []*codeField) string {
+	locals := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Array {
+			locals[i] = "model.Array(" + key.Local + ")"
+		} else {
+			locals[i] = key.Local
+		}
+	}
+	return strings.Join(locals, ", ")
+}
+
+// copyFields Returns the fields that the inserts are written from, which are the columns of the temporary table of a
+// COPY
+func (w *codeWriter) copyFields() []*codeField {
+	fields := make([]*codeField, 0)
+	seen := make(map[*codeField]bool)
+	for _, write := range w.t.Inserts {
+		for _, field := range write.Params {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+func (w *codeWriter) writeCreateMany() {
+	t := w.t
+	if len(t.Inserts) == 0 || !t.cfg.GeneratesOperation(t.table.TableSchema, t.table.TableName, "create") {
+		return
+	}
+
+	// The values are formatted with the placeholders of each row by the generated code
+	values := make([]string, len(t.Inserts))
+	for i, write := range t.Inserts {
+		values[i] = write.Value
+	}
+
+	copyTable := codePackage(t.table.TableSchema + "_" + t.table.TableName + "_copy")
+	fields := w.copyFields()
+	copyColumns := make([]string, len(fields))
+	copyNames := make([]string, len(fields))
+	copyArgs := make([]string, len(fields))
+	for i, field := range fields {
+		copyColumns[i] = field.Name + " " + field.SQLType
+		copyNames[i] = strconv.Quote(field.Name)
+		copyArgs[i] = nullableArg("nullable", field)
+	}
+	names := make([]interface{}, 0)
+	for _, write := range t.Inserts {
+		for _, field := range write.Params {
+			names = append(names, field.Name)
+		}
+	}
+	selects := make([]string, len(t.Inserts))
+	offset := 0
+	for i, write := range t.Inserts {
+		selects[i] = fmt.Sprintf(write.Value, names[offset:offset+len(write.Params)]...)
+		offset += len(write.Params)
+	}
+
+	w.doc("Bulk inserts. A multi-row INSERT has a VALUES per %s, and a COPY goes through a temporary table so that "+
+		"the insert transforms are applied", t.Noun)
+	w.p("const %s = \"INSERT INTO \" + %s + %s\n", w.name("InsertManyStr"), w.name("Table"),
+		strconv.Quote(" ("+writeColumns(t.Inserts)+") VALUES "))
+	w.p("const %s = %s\n", w.name("InsertValuesStr"), strconv.Quote("("+strings.Join(values, ", ")+")"))
+	w.p("const %s = \" RETURNING \" + %s\n", w.name("InsertReturningStr"), w.name("Columns"))
+	w.p("const %s = %d\n", w.name("InsertParams"), writeParams(t.Inserts))
+	w.p("const %s = %s\n", w.name("CopyTableStr"), strconv.Quote("CREATE TEMPORARY TABLE "+copyTable+" ("+
+		strings.Join(copyColumns, ", ")+") ON COMMIT DROP"))
+	w.p("const %s = \"INSERT INTO \" + %s + %s\n", w.name("CopyInsertStr"), w.name("Table"),
+		strconv.Quote(" ("+writeColumns(t.Inserts)+") SELECT "+strings.Join(selects, ", ")+" FROM "+copyTable))
+	w.p("const %s = %s\n", w.name("CopyDropStr"), strconv.Quote("DROP TABLE "+copyTable))
+	w.p("\nvar %s = pq.CopyIn(%s, %s)\n", w.name("CopyStr"), strconv.Quote(copyTable), strings.Join(copyNames, ", "))
+
+	table := w.name("Table")
+	locals, local := t.Locals, t.Local
+	w.doc("Create%s Creates the %s with multi-row INSERTs, each of as many %s as the bind parameters allow, and "+
+		"reads back every %s that was created. The %s are created in a transaction, so either all of them are "+
+		"created or none are.", t.Plural, t.Nouns, t.Nouns, t.Noun, t.Nouns)
+	w.p("func Create%s(ctx context.Context, db model.DBTX, %s []*%s) (err error) {\n", t.Plural, locals, t.GoName)
+	if w.validates() {
+		w.p("for _, %s := range %s {\n", local, locals)
+		w.validate(local, "create many", "")
+		w.p("}\n")
+	}
+	w.p("if len(%s) == 0 {\nreturn nil\n}\n\n", locals)
+	w.p("chunkSize := model.ChunkSize(%s)\n", w.name("InsertParams"))
+	w.p("return model.WrapError(%s, \"create many\", model.WithTx(ctx, db, nil, func(tx model.DBTX) error {\n", table)
+	w.p("for start := 0; start < len(%s); start += chunkSize {\n", locals)
+	w.p("end := start + chunkSize\nif end > len(%s) {\nend = len(%s)\n}\n", locals, locals)
+	w.p("if err := create%s(ctx, tx, %s[start:end]); err != nil {\nreturn err\n}\n}\nreturn nil\n}))\n}\n", t.Plural,
+		locals)
+
+	w.doc("create%s Creates a chunk of %s with one INSERT. The rows are returned in the order of the VALUES",
+		t.Plural, t.Nouns)
+	w.p("func create%s(ctx context.Context, db model.DBTX, %s []*%s) (err error) {\n", t.Plural, locals, t.GoName)
+	w.p("values := make([]string, len(%s))\n", locals)
+	w.p("args := make([]interface{}, 0, len(%s)*%s)\n", locals, w.name("InsertParams"))
+	w.p("for i, %s := range %s {\n", local, locals)
+	w.p("values[i] = fmt.Sprintf(%s, model.Placeholders(i, %s)...)\n", w.name("InsertValuesStr"),
+		w.name("InsertParams"))
+	w.p("nullable := toNullable%s(%s)\n", t.GoName, local)
+	w.p("args = append(args, %s)\n}\n\n", writeArgs("nullable", nil, t.Inserts))
+	w.p("rows, err := db.QueryContext(ctx, %s+strings.Join(values, \", \")+%s, args...)\n", w.name("InsertManyStr"),
+		w.name("InsertReturningStr"))
+	w.p("if err != nil {\nreturn err\n}\ndefer rows.Close()\n\n")
+	w.p("var returning = nullable%s{}\n", t.GoName)
+	w.p("for _, %s := range %s {\n", local, locals)
+	w.p("if !rows.Next() {\nreturn model.NoRow(rows)\n}\n")
+	w.p("if err := rows.Scan(%s); err != nil {\nreturn err\n}\n", w.scanArgs("returning"))
+	w.p("fromNullable%s(%s, returning)\n}\nreturn rows.Err()\n}\n", t.GoName, local)
+
+	w.doc("Copy%s Creates the %s with the COPY protocol, which is the fastest way to load many rows but does not "+
+		"read them back, so the columns that are assigned by the database are not set. Returns the number of %s "+
+		"that were created.", t.Plural, t.Nouns, t.Nouns)
+	w.p("func Copy%s(ctx context.Context, db model.DBTX, %s []*%s) (count int64, err error) {\n", t.Plural, locals,
+		t.GoName)
+	if w.validates() {
+		w.p("for _, %s := range %s {\n", local, locals)
+		w.validate(local, "copy", "0, ")
+		w.p("}\n")
+	}
+	w.p("if len(%s) == 0 {\nreturn 0, nil\n}\n\n", locals)
+	w.p("err = model.WithTx(ctx, db, nil, func(tx model.DBTX) error {\n")
+	w.p("if _, err := tx.ExecContext(ctx, %s); err != nil {\nreturn err\n}\n\n", w.name("CopyTableStr"))
+	w.p("err := model.CopyIn(ctx, tx, %s, len(%s), func(row int) []interface{} {\n", w.name("CopyStr"), locals)
+	w.p("nullable := toNullable%s(%s[row])\n", t.GoName, locals)
+	w.p("return []interface{}{%s}\n})\n", strings.Join(copyArgs, ", "))
+	w.p("if err != nil {\nreturn err\n}\n\n")
+	w.p("result, err := tx.ExecContext(ctx, %s)\nif err != nil {\nreturn err\n}\n", w.name("CopyInsertStr"))
+	w.p("if count, err = result.RowsAffected(); err != nil {\nreturn err\n}\n\n")
+	w.p("_, err = tx.ExecContext(ctx, %s)\nreturn err\n})\n", w.name("CopyDropStr"))
+	w.p("if err != nil {\nreturn 0, model.WrapError(%s, \"copy\", err)\n}\nreturn count, nil\n}\n", table)
+}
+
+func (w *codeWriter) writeUpserts() {
+	t := w.t
+	inserted := make(map[string]bool)
+	for _, write := range t.Inserts {
+		inserted[write.Column] = true
+	}
+
+	for _, index := range t.Upserts {
+		upsertable := true
+		for _, key := range index.Columns {
+			upsertable = upsertable && inserted[key]
+		}
+		if !upsertable {
+			continue
+		}
+
+		var extra []string
+		if t.Version != nil {
+			extra = append(extra, VersionSet(t.Version.Column, t.table.TableSchema+"."+t.table.TableName))
+		}
+		clause := UpsertClause(index, t.cfg.UpsertColumns(t.table, index), extra...)
+		name := index.UpsertName()
+		op := strings.ReplaceAll(strcase.ToSnake(name), "_", " ")
+		w.p("\nconst %s = %s + %s\n", w.name(name+"Str"), w.insertSQL(" "+clause+" RETURNING "), w.name("Columns"))
+		w.doc("%s Creates the %s, or overwrites the %s with the same %s when there is one, and reads back the row "+
+			"that was written", name, t.Noun, t.Noun, strings.Join(index.Columns, " and "))
+		w.p("func (m *%s) %s(ctx context.Context, db model.DBTX) (err error) {\n", t.GoName, name)
+		w.validate("m", op, "")
+		w.p("\nnullable := toNullable%s(m)\n", t.GoName)
+		w.readOne(op, w.name(name+"Str"), writeArgs("nullable", nil, t.Inserts), "model.NoRow(rows)")
+	}
+}
+
+// reads Returns true if the functions that read rows are generated
+func (w *codeWriter) reads() bool {
+	return w.t.cfg.GeneratesOperation(w.t.table.TableSchema, w.t.table.TableName, "read")
+}
+
+func (w *codeWriter) writeRead() {
+	t := w.t
+	if t.Keys == nil || !w.reads() {
+		return
+	}
+
+	w.p("\nconst %s = \"SELECT \" + %s + \" FROM \" + %s + %s\n", w.name("SelectStr"), w.name("Columns"),
+		w.name("Table"), strconv.Quote(" WHERE "+keyWhere(t.Keys, 0)))
+	w.doc("Read Reads the %s with the %s, returning model.ErrNotFound when there is none", t.Noun,
+		strings.Join(keyNames(t.Keys), " and "))
+	w.p("func (m *%s) Read(ctx context.Context, db model.DBTX, %s) (err error) {\n", t.GoName, keyParams(t.Keys))
+	w.readOne("read", w.name("SelectStr"), keyLocals(t.Keys), "model.NoRow(rows)")
+}
+
+// insertSQL Returns the constant expression of an INSERT of the record, ending with the rest of the statement
+func (w *codeWriter) insertSQL(rest string) string {
+	t := w.t
+	values := " DEFAULT VALUES"
+	if len(t.Inserts) > 0 {
+		values = " (" + writeColumns(t.Inserts) + ") VALUES (" + strings.Join(writeValues(t.Inserts, 0), ", ") + ")"
+	}
+	return "\"INSERT INTO \" + " + w.name("Table") + " + " + strconv.Quote(values+rest)
+}
+
+func (w *codeWriter) writeCreate() {
+	t := w.t
+	if !t.cfg.GeneratesOperation(t.table.TableSchema, t.table.TableName, "create") {
+		return
+	}
+
+	w.p("\nconst %s = %s + %s\n", w.name("InsertStr"), w.insertSQL(" RETURNING "), w.name("Columns"))
+	w.doc("Create Inserts the %s and reads back the row that was written, which sets the columns that are assigned "+
+		"by the database", t.Noun)
+	w.p("func (m *%s) Create(ctx context.Context, db model.DBTX) (err error) {\n", t.GoName)
+	w.validate("m", "create", "")
+	args := ""
+	if len(t.Inserts) > 0 {
+		w.p("\nnullable := toNullable%s(m)\n", t.GoName)
+		args = writeArgs("nullable", nil, t.Inserts)
+	}
+	w.readOne("create", w.name("InsertStr"), args, "model.NoRow(rows)")
+}
+
+// noVersion Writes the check that the message has the version that it was read with
+func (w *codeWriter) noVersion(op string, zero string) {
+	w.p("if m.%s == nil {\n", w.t.Version.GoField)
+	w.p("return %smodel.WrapError(%s, %s, model.ErrNoVersion)\n}\n", zero, w.name("Table"), strconv.Quote(op))
+}
+
+// staleOrNoRow Returns the error of a write by key that matched no row
+func (w *codeWriter) staleOrNoRow() string {
+	if w.t.Version == nil {
+		return "model.NoRow(rows)"
+	}
+	return "model.StaleOrNoRow(ctx, db, rows, " + w.name("ExistsStr") + ", " + w.keyArgs() + ")"
+}
+
+// writeExists Writes the query of whether the row with the primary key exists, which tells a stale write from one of
+// a row that does not exist
+func (w *codeWriter) writeExists() {
+	t := w.t
+	if t.Version != nil {
+		w.p("\nconst %s = \"SELECT EXISTS (SELECT 1 FROM \" + %s + %s\n", w.name("ExistsStr"), w.name("Table"),
+			strconv.Quote(" WHERE "+keyWhere(t.Keys, 0)+")"))
+	}
+}
+
+func (w *codeWriter) writeUpdate() {
+	t := w.t
+	if len(t.Updates) == 0 {
+		return
+	}
+
+	w.writeExists()
+	set := writeValues(t.Updates, len(t.Keys))
+	for i, write := range t.Updates {
+		set[i] = write.Column + " = " + set[i]
+	}
+	where := keyWhere(t.Keys, 0)
+	var extra []*codeField
+	if t.Version != nil {
+		set = append(set, VersionSet(t.Version.Column, ""))
+		where += " AND " + t.Version.Name + " = $" + strconv.Itoa(len(t.Keys)+writeParams(t.Updates)+1)
+		extra = append(extra, t.Version)
+	}
+	w.p("\nconst %s = \"UPDATE \" + %s + %s + %s\n", w.name("UpdateStr"), w.name("Table"),
+		strconv.Quote(" SET "+strings.Join(set, ", ")+" WHERE "+where+" RETURNING "), w.name("Columns"))
+
+	if t.Version != nil {
+		w.doc("Update Writes every column of the %s when its version is still the one that was read, and moves the "+
+			"version forward. Returns model.ErrStaleWrite when the %s was changed since, or model.ErrNotFound when it "+
+			"no longer exists. A %s without a version returns model.ErrNoVersion.", t.Noun, t.Noun, t.Noun)
+	} else {
+		w.doc("Update Writes every column of the %s and reads back the row that was written, returning "+
+			"model.ErrNotFound when it does not exist", t.Noun)
+	}
+	w.p("func (m *%s) Update(ctx context.Context, db model.DBTX) (err error) {\n", t.GoName)
+	if t.Version != nil {
+		w.noVersion("update", "")
+	}
+	w.validate("m", "update", "")
+	w.p("\nnullable := toNullable%s(m)\n", t.GoName)
+	w.readOne("update", w.name("UpdateStr"), writeArgs("nullable", t.Keys, t.Updates, extra...), w.staleOrNoRow())
+
+	w.writeUpdateMask()
+}
+
+func (w *codeWriter) writeUpdateMask() {
+	t := w.t
+	if len(t.Masks) == 0 {
+		return
+	}
+
+	where := keyWhere(t.Keys, 0)
+	version := ""
+	offset := len(t.Keys)
+	if t.Version != nil {
+		version = ", " + VersionSet(t.Version.Column, "")
+		where += " AND " + t.Version.Name + " = $" + strconv.Itoa(len(t.Keys)+1)
+		offset++
+	}
+	w.doc("Partial updates, which SET the columns of the paths of a field mask")
+	w.p("const %s = \"UPDATE \" + %s + \" SET \"\n", w.name("UpdateMaskStr"), w.name("Table"))
+	w.p("const %s = %s + %s\n", w.name("UpdateMaskWhereStr"), strconv.Quote(version+" WHERE "+where+" RETURNING "),
+		w.name("Columns"))
+	w.p("\nvar %s = []model.MaskColumn{\n", w.name("MaskColumns"))
+	for _, mask := range t.Masks {
+		paths := make([]string, len(mask.Paths))
+		for i, path := range mask.Paths {
+			paths[i] = strconv.Quote(path)
+		}
+		w.p("{Column: %s, Value: %s, Paths: []string{%s}},\n", strconv.Quote(mask.Column), strconv.Quote(mask.Value),
+			strings.Join(paths, ", "))
+	}
+	w.p("}\n")
+
+	together := make([]string, 0)
+	for _, mask := range t.Masks {
+		if len(mask.Paths) > 1 {
+			together = append(together, strings.Join(mask.Paths, " and "))
+		}
+	}
+	if len(together) > 0 {
+		together[0] = ", and " + together[0]
+		together[len(together)-1] += " have to be updated together"
+	}
+	w.doc("UpdateMask Updates only the fields of the %s that are in the mask, leaving the other columns as they are, "+
+		"and reads back the whole %s. The paths are the names of the fields of the proto message%s. A nil or empty "+
+		"mask updates every field, as Update does.", t.Noun, t.Noun, strings.Join(together, ", "))
+	w.p("func (m *%s) UpdateMask(ctx context.Context, db model.DBTX, mask *fieldmaskpb.FieldMask) (err error) {\n",
+		t.GoName)
+	w.p("if len(mask.GetPaths()) == 0 {\nreturn m.Update(ctx, db)\n}\n\n")
+	if t.Version != nil {
+		w.noVersion("update mask", "")
+	}
+	w.p("set, fields, err := model.MaskSet(mask.GetPaths(), %s, %d)\n", w.name("MaskColumns"), offset)
+	w.p("if err != nil {\nreturn model.WrapError(%s, \"update mask\", err)\n}\n\n", w.name("Table"))
+
+	args := make([]string, 0, len(t.Keys)+1)
+	for _, key := range t.Keys {
+		args = append(args, messageArg(key))
+	}
+	if t.Version != nil {
+		args = append(args, "*m."+t.Version.GoField)
+	}
+	w.p("args := []interface{}{%s}\n", strings.Join(args, ", "))
+	w.p("for _, field := range fields {\nswitch field {\n")
+	seen := make(map[string]bool)
+	for _, mask := range t.Masks {
+		for _, field := range t.pathFields(mask.Paths) {
+			if seen[field.Path] {
+				continue
+			}
+			seen[field.Path] = true
+			w.p("case %s:\n", strconv.Quote(field.Path))
+			if field.Required {
+				w.p("if m.%s == nil {\n", field.GoField)
+				w.p("return model.WrapError(%s, \"update mask\", &model.ErrNotNullViolation{Column: %s})\n}\n",
+					w.name("Table"), strconv.Quote(field.Name))
+				w.p("args = append(args, *m.%s)\n", field.GoField)
+			} else {
+				w.p("args = append(args, %s)\n", messageArg(field))
+			}
+		}
+	}
+	w.p("}\n}\n\n")
+	w.readOne("update mask", w.name("UpdateMaskStr")+"+set+"+w.name("UpdateMaskWhereStr"), "args...",
+		w.staleOrNoRow())
+}
+
+func (w *codeWriter) writeDelete() {
+	t := w.t
+	if t.Keys == nil || !t.cfg.GeneratesOperation(t.table.TableSchema, t.table.TableName, "delete") {
+		return
+	}
+
+	where := keyWhere(t.Keys, 0)
+	args := w.keyArgs()
+	if t.Version != nil {
+		where += " AND " + t.Version.Name + " = $" + strconv.Itoa(len(t.Keys)+1)
+		args += ", m." + t.Version.GoField
+		if len(t.Updates) == 0 {
+			w.writeExists()
+		}
+	}
+	w.p("\nconst %s = \"DELETE FROM \" + %s + %s\n", w.name("DeleteStr"), w.name("Table"),
+		strconv.Quote(" WHERE "+where))
+
+	table := w.name("Table")
+	if t.Version == nil {
+		w.doc("Delete Deletes the %s, returning the number of rows that were deleted, which is 0 when the %s does not "+
+			"exist", t.Noun, t.Noun)
+		w.p("func (m *%s) Delete(ctx context.Context, db model.DBTX) (count int64, err error) {\n", t.GoName)
+		w.p("result, err := db.ExecContext(ctx, %s, %s)\n", w.name("DeleteStr"), args)
+		w.p("if err != nil {\nreturn 0, model.WrapError(%s, \"delete\", err)\n}\n", table)
+		w.p("count, err = result.RowsAffected()\nreturn count, model.WrapError(%s, \"delete\", err)\n}\n", table)
+		return
+	}
+
+	w.doc("Delete Deletes the %s when its version is still the one that was read, returning model.ErrStaleWrite when "+
+		"the %s was changed since, or model.ErrNoVersion when it has no version. Deleting a %s that does not exist "+
+		"deletes nothing.", t.Noun, t.Noun, t.Noun)
+	w.p("func (m *%s) Delete(ctx context.Context, db model.DBTX) (count int64, err error) {\n", t.GoName)
+	w.noVersion("delete", "0, ")
+	w.p("result, err := db.ExecContext(ctx, %s, %s)\n", w.name("DeleteStr"), args)
+	w.p("if err != nil {\nreturn 0, model.WrapError(%s, \"delete\", err)\n}\n", table)
+	w.p("if count, err = result.RowsAffected(); err != nil || count > 0 {\n")
+	w.p("return count, model.WrapError(%s, \"delete\", err)\n}\n\n", table)
+	w.p("var exists bool\n")
+	w.p("if err := db.QueryRowContext(ctx, %s, %s).Scan(&exists); err != nil {\n", w.name("ExistsStr"), w.keyArgs())
+	w.p("return 0, model.WrapError(%s, \"delete\", err)\n", table)
+	w.p("} else if exists {\nreturn 0, model.WrapError(%s, \"delete\", model.ErrStaleWrite)\n}\n", table)
+	w.p("return 0, nil\n}\n")
+}
+
+func (w *codeWriter) writeList() {
+	t := w.t
+	if t.Order == nil || !w.reads() {
+		return
+	}
+
+	names := keyNames(t.Order)
+	order := strings.Join(names, ", ")
+	after := make([]string, len(t.Order))
+	for i := range t.Order {
+		after[i] = "$" + strconv.Itoa(i+2)
+	}
+	condition := order + " > " + strings.Join(after, ", ")
+	if len(t.Order) > 1 {
+		condition = "(" + order + ") > (" + strings.Join(after, ", ") + ")"
+	}
+	w.p("\nconst %s = \"SELECT \" + %s + \" FROM \" + %s + %s\n", w.name("ListStr"), w.name("Columns"),
+		w.name("Table"), strconv.Quote(" ORDER BY "+order+" LIMIT $1"))
+	w.p("const %s = \"SELECT \" + %s + \" FROM \" + %s + %s\n", w.name("ListAfterStr"), w.name("Columns"),
+		w.name("Table"), strconv.Quote(" WHERE "+condition+" ORDER BY "+order+" LIMIT $1"))
+
+	table := w.name("Table")
+	zero := "[]" + t.GoName + "{}, \"\", "
+	w.doc("List%s Returns a page of at most limit %s ordered by %s, starting after the cursor. The cursor of the "+
+		"first page is empty, and next is the cursor of the following page or empty when this is the last page. A "+
+		"limit above model.MaxPageSize is lowered to it, and a limit that is not positive returns "+
+		"model.ErrInvalidLimit.", t.Plural, t.Nouns, order)
+	w.p("func List%s(ctx context.Context, db model.DBTX, limit int32, cursor string) (%s []%s, next string, "+
+		"err error) {\n", t.Plural, t.Locals, t.GoName)
+	w.p("limit, err = model.PageSize(limit)\n")
+	w.p("if err != nil {\nreturn %smodel.WrapError(%s, \"list\", err)\n}\n\n", zero, table)
+
+	vars := make([]string, len(t.Order))
+	pointers := make([]string, len(t.Order))
+	lasts := make([]string, len(t.Order))
+	for i, field := range t.Order {
+		vars[i] = "after" + field.GoField
+		pointers[i] = "&" + vars[i]
+		lasts[i] = "last." + field.GoField
+		w.p("var %s %s\n", vars[i], field.Scalar.Type)
+	}
+	w.p("after, err := model.DecodeCursor(cursor, %s)\n", strings.Join(pointers, ", "))
+	w.p("if err != nil {\nreturn %smodel.WrapError(%s, \"list\", err)\n}\n\n", zero, table)
+	w.p("// One more row than the page is read to know whether there is a next page\n")
+	w.p("var rows *sql.Rows\nif after {\n")
+	w.p("rows, err = db.QueryContext(ctx, %s, limit+1, %s)\n", w.name("ListAfterStr"), strings.Join(vars, ", "))
+	w.p("} else {\nrows, err = db.QueryContext(ctx, %s, limit+1)\n}\n", w.name("ListStr"))
+	w.p("if err != nil {\nreturn %smodel.WrapError(%s, \"list\", err)\n}\ndefer rows.Close()\n\n", zero, table)
+	w.p("results := make([]%s, 0, limit)\n", t.GoName)
+	w.p("var returning = nullable%s{}\n", t.GoName)
+	w.p("for rows.Next() {\nif int32(len(results)) == limit {\n")
+	w.p("last := results[len(results)-1]\n")
+	w.p("if next, err = model.EncodeCursor(%s); err != nil {\n", strings.Join(lasts, ", "))
+	w.p("return %smodel.WrapError(%s, \"list\", err)\n}\nbreak\n}\n\n", zero, table)
+	w.p("result := %s{}\n", t.GoName)
+	w.p("if err := rows.Scan(%s); err != nil {\n", w.scanArgs("returning"))
+	w.p("return %smodel.WrapError(%s, \"list\", err)\n}\n\n", zero, table)
+	w.p("fromNullable%s(&result, returning)\nresults = append(results, result)\n}\n\n", t.GoName)
+	w.p("if err := rows.Err(); err != nil {\nreturn %smodel.WrapError(%s, \"list\", err)\n}\n", zero, table)
+	w.p("return results, next, nil\n}\n")
+}
+
+func (w *codeWriter) writeCount() {
+	t := w.t
+	if !w.reads() {
+		return
+	}
+
+	table := w.name("Table")
+	w.p("\nconst %s = \"SELECT count(*) FROM \" + %s\n", w.name("CountStr"), table)
+	w.p("const %s = \"SELECT reltuples::bigint FROM pg_class WHERE oid = '\" + %s + \"'::regclass\"\n",
+		w.name("EstimateCountStr"), table)
+	w.doc("Count%s Returns the number of %s. Counting is a scan of the whole table, so unless exact is set the "+
+		"estimate of the planner is returned, which is only as recent as the last ANALYZE. The table is counted when "+
+		"there is no estimate, since a table that has never been analyzed is estimated as -1, or as 0 by older "+
+		"versions of postgres.", t.Plural, t.Nouns)
+	w.p("func Count%s(ctx context.Context, db model.DBTX, exact bool) (count int64, err error) {\n", t.Plural)
+	w.p("if !exact {\n")
+	w.p("if err := db.QueryRowContext(ctx, %s).Scan(&count); err != nil {\n", w.name("EstimateCountStr"))
+	w.p("return 0, model.WrapError(%s, \"count\", err)\n}\n", table)
+	w.p("if count > 0 {\nreturn count, nil\n}\n}\n\n")
+	w.p("if err := db.QueryRowContext(ctx, %s).Scan(&count); err != nil {\n", w.name("CountStr"))
+	w.p("return 0, model.WrapError(%s, \"count\", err)\n}\nreturn count, nil\n}\n", table)
+}
+
+func (w *codeWriter) writeFind() {
+	t := w.t
+	if len(t.Filters) == 0 || !w.reads() {
+		return
+	}
+
+	key := t.Keys
+	if key == nil {
+		key = t.Order
+	}
+	keys := make([]string, len(key))
+	for i, name := range keyNames(key) {
+		keys[i] = strconv.Quote(name)
+	}
+	sortable := make([]string, len(t.Filters))
+	for i, field := range t.Filters {
+		sortable[i] = strconv.Quote(field.Name)
+	}
+
+	w.doc("%sFilter The conditions of Find%s, where every filter that is set must hold", t.GoName, t.Plural)
+	w.p("type %sFilter struct {\n", t.GoName)
+	for _, field := range t.Filters {
+		w.p("%s %s\n", field.GoField, FilterType(field.Column))
+	}
+	w.p("}\n")
+	w.doc("The columns that Find%s can sort by", t.Plural)
+	w.p("var %s = []string{%s}\n", w.name("SortColumns"), strings.Join(sortable, ", "))
+
+	table := w.name("Table")
+	zero := "[]" + t.GoName + "{}, "
+	then := ""
+	if len(keys) > 0 {
+		then = " and then by " + strings.Join(keyNames(key), ", ")
+	}
+	w.doc("Find%s Returns at most limit %s matching the filter, sorted by the columns of sort%s, skipping the first "+
+		"offset. The filter may be nil to match every %s. Sorting by a column that is not sortable returns "+
+		"model.ErrInvalidSort. The limit is checked like the limit of List%s.", t.Plural, t.Nouns, then, t.Noun,
+		t.Plural)
+	w.p("func Find%s(ctx context.Context, db model.DBTX, filter *%sFilter, sort []model.Sort, limit int32,\n"+
+		"offset int32) (%s []%s, err error) {\n", t.Plural, t.GoName, t.Locals, t.GoName)
+	w.p("limit, err = model.PageSize(limit)\n")
+	w.p("if err != nil {\nreturn %smodel.WrapError(%s, \"find\", err)\n}\n\n", zero, table)
+	w.p("var where model.Where\nif filter != nil {\n")
+	for _, field := range t.Filters {
+		method := "Bool"
+		switch FilterType(field.Column) {
+		case "*model.Int32Filter":
+			method = "Int32"
+		case "*model.StringFilter":
+			method = "String"
+		}
+		w.p("where.%s(%s, filter.%s)\n", method, strconv.Quote(field.Name), field.GoField)
+	}
+	w.p("}\n\n")
+	w.p("orderBy, err := model.OrderBy(sort, %s", w.name("SortColumns"))
+	if len(keys) > 0 {
+		w.p(", %s", strings.Join(keys, ", "))
+	}
+	w.p(")\nif err != nil {\nreturn %smodel.WrapError(%s, \"find\", err)\n}\n\n", zero, table)
+	w.p("query := \"SELECT \" + %s + \" FROM \" + %s + where.SQL() + orderBy + \" LIMIT \" + where.Param(limit) + "+
+		"\" OFFSET \" + where.Param(offset)\n", w.name("Columns"), table)
+	w.readMany("find", "query", "where.Args()...", "limit")
+}
+
+func (w *codeWriter) writeLookups() {
+	t := w.t
+	if !w.reads() {
+		return
+	}
+
+	for _, lookup := range t.Lookups {
+		columns := strings.Join(keyNames(lookup.Params), " and ")
+		op := "lookup " + strings.ReplaceAll(strcase.ToSnake(lookup.Name), "_", " ")
+		where := lookup.Index.LookupWhere(t.table, 0)
+
+		if lookup.Index.IndexType != NonUnique {
+			w.p("\nconst %s = \"SELECT \" + %s + \" FROM \" + %s + %s\n", w.name("Lookup"+lookup.Name+"Str"),
+				w.name("Columns"), w.name("Table"), strconv.Quote(" WHERE "+where))
+			w.doc("Lookup%s Reads the %s with the %s, returning model.ErrNotFound when there is none", lookup.Name,
+				t.Noun, columns)
+			w.p("func (m *%s) Lookup%s(ctx context.Context, db model.DBTX, %s) (err error) {\n", t.GoName, lookup.Name,
+				keyParams(lookup.Params))
+			w.readOne(op, w.name("Lookup"+lookup.Name+"Str"), keyLocals(lookup.Params), "model.NoRow(rows)")
+			continue
+		}
+
+		order := ""
+		if t.Keys != nil {
+			order = " ORDER BY " + strings.Join(keyNames(t.Keys), ", ")
+		}
+		name := "Lookup" + t.Plural + "By" + lookup.Name
+		w.p("\nconst %s = \"SELECT \" + %s + \" FROM \" + %s + %s\n", w.name(name+"Str"), w.name("Columns"),
+			w.name("Table"), strconv.Quote(" WHERE "+where+order+" LIMIT $"+strconv.Itoa(len(lookup.Params)+1)))
+		w.doc("%s Returns at most limit %s with the %s. The limit is checked like the limit of List%s.", name,
+			t.Nouns, columns, t.Plural)
+		w.p("func %s(ctx context.Context, db model.DBTX, %s, limit int32) (%s []%s, err error) {\n", name,
+			keyParams(lookup.Params), t.Locals, t.GoName)
+		w.p("limit, err = model.PageSize(limit)\n")
+		w.p("if err != nil {\nreturn []%s{}, model.WrapError(%s, %s, err)\n}\n\n", t.GoName, w.name("Table"),
+			strconv.Quote(op))
+		w.readMany(op, w.name(name+"Str"), keyLocals(lookup.Params)+", limit", "limit")
+	}
+}
+
+func (w *codeWriter) writeMappings() {
+	t := w.t
+	table := w.name("Table")
+	for _, mapping := range t.Mappings {
+		params := make([]string, len(mapping.Params))
+		args := make([]string, len(mapping.Params))
+		for i, param := range mapping.Params {
+			goType := param.Type
+			if goType == "" {
+				goType = "interface{}"
+			}
+			params[i] = param.Name + " " + goType
+			args[i] = param.Name
+		}
+		query := w.name(mapping.FuncName + "Str")
+		if len(args) > 0 {
+			query += ", " + strings.Join(args, ", ")
+		}
+		signature := "ctx context.Context, db model.DBTX"
+		if len(params) > 0 {
+			signature += ", " + strings.Join(params, ", ")
+		}
+
+		w.p("\nconst %s = %s\n", w.name(mapping.FuncName+"Str"), strconv.Quote(mapping.Query))
+		if mapping.Rows {
+			w.doc("%s Runs the %s query, returning its rows as maps of their columns", mapping.FuncName,
+				mapping.Name)
+			w.p("func %s(%s) (results []map[string]interface{}, err error) {\n", mapping.FuncName, signature)
+			w.p("results, err = model.ReadResults(db.QueryContext(ctx, %s))\n", query)
+			w.p("return results, model.WrapError(%s, %s, err)\n}\n", table, strconv.Quote(mapping.Name))
+			continue
+		}
+		w.doc("%s Runs the %s query, returning the number of rows it changed", mapping.FuncName, mapping.Name)
+		w.p("func %s(%s) (count int64, err error) {\n", mapping.FuncName, signature)
+		w.p("result, err := db.ExecContext(ctx, %s)\n", query)
+		w.p("if err != nil {\nreturn 0, model.WrapError(%s, %s, err)\n}\n", table, strconv.Quote(mapping.Name))
+		w.p("count, err = result.RowsAffected()\nreturn count, model.WrapError(%s, %s, err)\n}\n", table,
+			strconv.Quote(mapping.Name))
+	}
+}
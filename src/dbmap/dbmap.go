@@ -1,11 +1,17 @@
 package dbmap
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"gopkg.in/yaml.v3"
+	"io"
 	"os"
+	"path"
+	"regexp"
+	"strings"
 )
 
 var InvalidArguments = errors.New("invalid argument")
@@ -19,11 +25,21 @@ type Config struct {
 		Database string `yaml:"database"`
 		Username string `yaml:"user"`
 		Password string `yaml:"password"`
+		// A file holding the password, such as a docker secret, used instead of password
+		PasswordFile string `yaml:"password_file"`
+		// The SSL mode of the connection, which is disable by default
+		SSLMode string `yaml:"sslmode"`
+		// A URL or key/value data source, used instead of the host, port, user, password, database and sslmode
+		DSN string `yaml:"dsn"`
+		// The size of the connection pool, which also bounds the number of schemas that are read concurrently
+		MaxConnections int `yaml:"max_connections"`
 	} `yaml:"database"`
 	Output struct {
 		Path   string `yaml:"path"`
 		Suffix string `yaml:"suffix"`
 		Lang   string `yaml:"lang"`
+		// The generators that are run by generate, in order, which are proto and code by default
+		Generators []GeneratorConfig `yaml:"generators"`
 	} `yaml:"output"`
 	EmbedRelationships bool `yaml:"embed_relationships"`
 	Proto              struct {
@@ -34,8 +50,10 @@ type Config struct {
 	} `yaml:"proto"`
 	Generator struct {
 		Schemas         []string `yaml:"schemas"`
+		IncludedTables  []string `yaml:"included_tables"`
 		ExcludedTables  []string `yaml:"excluded_tables"`
 		IndexedLookups  bool     `yaml:"indexed_lookups"`
+		StrictFilters   bool     `yaml:"strict_filters"`
 		ExcludedColumns []struct {
 			Tablename string   `yaml:"table"`
 			Columns   []string `yaml:"columns"`
@@ -50,24 +68,28 @@ type Config struct {
 		Transforms []struct {
 			Tablename string `yaml:"table"`
 			Xforms    struct {
-				Select []struct {
-					Columnname string `yaml:"column"`
-					Datatype   string `yaml:"data_type"`
-					Xform      string `yaml:"xform"`
-				} `yaml:"select"`
-				Insert []struct {
-					Columnname string `yaml:"column"`
-					Datatype   string `yaml:"data_type"`
-					Xform      string `yaml:"xform"`
-				}
-				Update []struct {
-					Columnname string `yaml:"column"`
-					Datatype   string `yaml:"data_type"`
-					Xform      string `yaml:"xform"`
-				}
+				Select []Xform `yaml:"select"`
+				Insert []Xform `yaml:"insert"`
+				Update []Xform `yaml:"update"`
 			} `yaml:"xforms"`
 		} `yaml:"transforms"`
+		// Overrides of the names, types and operations generated for tables and their columns
+		Tables []TableOverride `yaml:"tables"`
 	} `yaml:"generator"`
+
+	// Where the progress of reading the database and generating the files is written, which is nowhere when nil. The
+	// command line writes it to stdout, or to stderr when stdout is the JSON of --json
+	Log io.Writer `yaml:"-"`
+
+	// The YAML the configuration was read from, used to report the line of a problem
+	node *yaml.Node
+}
+
+// Xform A transformation applied to a column as it is selected, inserted or updated
+type Xform struct {
+	Columnname string `yaml:"column"`
+	Datatype   string `yaml:"data_type"`
+	Xform      string `yaml:"xform"`
 }
 
 type RelationType int
@@ -83,12 +105,14 @@ type ForeignColumns struct {
 	OrdinalPosition int32
 }
 
+// ForeignRelation A foreign key of a table, named by its constraint, and the columns of the table it references
 type ForeignRelation struct {
-	ForeignSchema string
-	ForeignTable  string
-	MapName       string
-	Columns       []ForeignColumns
-	RelationType  RelationType
+	ConstraintName string
+	ForeignSchema  string
+	ForeignTable   string
+	MapName        string
+	Columns        []ForeignColumns
+	RelationType   RelationType
 }
 
 type IndexType int
@@ -99,15 +123,48 @@ const (
 	NonUnique  IndexType = 2
 )
 
+// Index The structure of an index. Columns are the keys of the index in order, where a key that is an expression, such
+// as lower(email), is the text of the expression and is also listed in Expressions. Include are the non-key columns of
+// a covering index, Predicate is the WHERE clause of a partial index and Method is the access method (btree, gin, ...)
 type Index struct {
 	TableSchema string
 	TableName   string
 	IndexName   string
 	IndexType   IndexType
 	Columns     []string
+	Expressions []string
+	Include     []string
+	Method      string
+	Predicate   string
 }
 
-// Column The structure of a column
+// Domain The structure of a user defined domain. A domain is always resolved through any chain of domains to its base
+// type, collecting the constraints of each domain along the way. The BaseType is the name of the base type in the
+// catalog, such as int4 or _text, and the BaseSchema is the schema it is in, such as pg_catalog
+type Domain struct {
+	DomainSchema string
+	DomainName   string
+	DataType     string
+	BaseSchema   string
+	BaseType     string
+	Default      string
+	IsNotNull    bool
+	Constraints  []string
+	Composite    *CompositeType
+}
+
+// CompositeType The structure of a user defined composite (row) type. The attributes are described as columns, where
+// the TableSchema and TableName are those of the type
+type CompositeType struct {
+	TypeSchema string
+	TypeName   string
+	Attributes []Column
+}
+
+// Column The structure of a column. If the column is defined by a domain, the DataType and UdtName are those of the
+// domains base type. Composite is set when the column (or the base type of its domain) is a composite type. For an
+// ARRAY, the ElementType is the resolved type of its elements and Composite is set for an array of a composite type.
+// ArrayDims is the number of dimensions that were declared, which is at least 1 for an ARRAY and 0 otherwise
 type Column struct {
 	TableName       string
 	TableSchema     string
@@ -119,48 +176,176 @@ type Column struct {
 	IsNullable      bool
 	IsSequence      bool
 	IsPrimaryKey    bool
+	Domain          *Domain
+	Composite       *CompositeType
+	ElementType     string
+	ArrayDims       int
 }
 
 // The structure of a table
 type Table struct {
-	TableName   string
-	TableSchema string
-	Columns     []Column
-	Indexes     []Index
-	Relations   []ForeignRelation
+	TableName      string
+	TableSchema    string
+	Columns        []Column
+	Indexes        []Index
+	Relations      []ForeignRelation
+	SkippedColumns []string
 }
 
 // The structure of a schema
 type Schema struct {
-	SchemaName string
-	Tables     []Table
+	SchemaName    string
+	Tables        []Table
+	SkippedTables []string
 }
 
 // The current database and the schema's we will generate code against
 type Database struct {
-	DB      *sql.DB
+	DB      *sql.DB `json:"-"`
 	Schemas []Schema
 }
 
+// Provider Reads the database described by the configuration
 type Provider interface {
-	ReadDatabase() *Database
+	ReadDatabase(ctx context.Context) (*Database, error)
 }
 
-func ReadFile(cfg *Config, configFile string) {
-	fmt.Println("Using configuration: ", configFile)
-	f, err := os.Open(configFile)
+// ReadFile Reads the configuration from the YAML file. Unknown fields are an error, so that a misspelled option is not
+// silently ignored. References to environment variables, ${VAR} or ${VAR:-default}, are replaced in every value and
+// the password is read from password_file when given. Every problem is returned as a ConfigError with its line
+func ReadFile(cfg *Config, configFile string) error {
+	cfg.Logf("Using configuration: %s\n", configFile)
+	b, err := os.ReadFile(configFile)
 	if err != nil {
-		processError(err)
+		return err
 	}
 
-	decoder := yaml.NewDecoder(f)
-	err = decoder.Decode(cfg)
-	if err != nil {
-		processError(err)
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return yamlErrors(err)
+	}
+	cfg.node = &node
+
+	// The fields are checked against the YAML as written, as a reference to a variable is not yet a valid value
+	var problems ConfigErrors
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&Config{}); err != nil && err != io.EOF {
+		for _, problem := range yamlErrors(err) {
+			if problem.(ConfigError).Message == "unknown field" {
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	problems = append(problems, interpolate(&node)...)
+	if len(node.Content) > 0 {
+		if err := node.Decode(cfg); err != nil {
+			problems = append(problems, yamlErrors(err)...)
+		}
+	}
+
+	if err := cfg.readPasswordFile(configFile); err != nil {
+		problems = append(problems, err)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// IsTableExcluded Returns true if the table is not in included_tables (when any are given) or is in excluded_tables
+func (cfg Config) IsTableExcluded(schemaName string, tableName string) bool {
+	if len(cfg.Generator.IncludedTables) > 0 {
+		included := false
+		for _, pattern := range cfg.Generator.IncludedTables {
+			if MatchTable(pattern, schemaName, tableName) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return true
+		}
+	}
+
+	for _, pattern := range cfg.Generator.ExcludedTables {
+		if MatchTable(pattern, schemaName, tableName) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsColumnExcluded Returns true if the column matches any of the excluded_columns of a matching table
+func (cfg Config) IsColumnExcluded(schemaName string, tableName string, columnName string) bool {
+	for _, excludedColumn := range cfg.Generator.ExcludedColumns {
+		if MatchTable(excludedColumn.Tablename, schemaName, tableName) {
+			for _, pattern := range excludedColumn.Columns {
+				if matchName(pattern, columnName) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// MatchTable Returns true if the pattern matches the table. A pattern is either a table name, which matches the table
+// in every schema, or a schema qualified table name. Both the schema and the table name may be glob patterns, such as
+// audit.*_log. A pattern enclosed in slashes, such as /^audit\..*_log$/, is a regular expression that is matched
+// against the schema qualified table name.
+func MatchTable(pattern string, schemaName string, tableName string) bool {
+	if isRegexp(pattern) {
+		return matchName(pattern, schemaName+"."+tableName)
+	}
+
+	schemaPattern := "*"
+	tablePattern := pattern
+	if i := strings.Index(pattern, "."); i >= 0 {
+		schemaPattern, tablePattern = pattern[:i], pattern[i+1:]
 	}
+	return matchName(schemaPattern, schemaName) && matchName(tablePattern, tableName)
 }
 
-func processError(err error) {
-	fmt.Println(err)
-	os.Exit(2)
+// matchName Matches a single name against a glob pattern, or a regular expression when enclosed in slashes
+func matchName(pattern string, name string) bool {
+	if isRegexp(pattern) {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		return err == nil && re.MatchString(name)
+	}
+
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
+
+func isRegexp(pattern string) bool {
+	return len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// Logf Writes the progress to the Log of the configuration, if it has one
+func (cfg Config) Logf(format string, args ...interface{}) {
+	if cfg.Log != nil {
+		_, _ = fmt.Fprintf(cfg.Log, format, args...)
+	}
+}
+
+// PrintSkipped Writes a summary of the tables and columns that were skipped while reading the database to w
+func PrintSkipped(w io.Writer, database *Database) {
+	count := 0
+	for _, schema := range database.Schemas {
+		for _, tableName := range schema.SkippedTables {
+			_, _ = fmt.Fprintf(w, "%s.%s (table)\n", schema.SchemaName, tableName)
+			count++
+		}
+
+		for _, table := range schema.Tables {
+			for _, columnName := range table.SkippedColumns {
+				_, _ = fmt.Fprintf(w, "%s.%s.%s (column)\n", table.TableSchema, table.TableName, columnName)
+				count++
+			}
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%d skipped\n", count)
 }
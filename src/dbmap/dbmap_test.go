@@ -8,7 +8,9 @@ const TestConfig = "src/dbmap/postgres/test_config.yml"
 
 func TestReadConfig(t *testing.T) {
 	var cfg Config
-	ReadFile(&cfg, TestConfig)
+	if err := ReadFile(&cfg, TestConfig); err != nil {
+		t.Fatal(err)
+	}
 
 	testDatabase(cfg, t)
 	testOutput(cfg, t)
@@ -84,11 +86,7 @@ func testGenerator(cfg Config, t *testing.T) {
 	}
 }
 
-func testXforms(which string, xforms []struct {
-	Columnname string `yaml:"column"`
-	Datatype   string `yaml:"data_type"`
-	Xform      string `yaml:"xform"`
-}, t *testing.T) {
+func testXforms(which string, xforms []Xform, t *testing.T) {
 	if len(xforms) != 0 {
 		for i, ee := range xforms {
 			if ee.Columnname == "" {
@@ -156,4 +154,76 @@ func testDatabase(cfg Config, t *testing.T) {
 	if cfg.Database.Password == "" {
 		t.Errorf("database.password must have a value")
 	}
-}
\ No newline at end of file
+}
+func TestMatchTable(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		schema   string
+		table    string
+		expected bool
+	}{
+		{"excluded", "public", "excluded", true},
+		{"excluded", "test_schema", "excluded", true},
+		{"public.excluded", "public", "excluded", true},
+		{"public.excluded", "test_schema", "excluded", false},
+		{"audit.*_log", "audit", "login_log", true},
+		{"audit.*_log", "audit", "login", false},
+		{"audit.*_log", "public", "login_log", false},
+		{"*_log", "public", "login_log", true},
+		{"*.user", "test_schema", "user", true},
+		{"/^audit\\..*_log$/", "audit", "login_log", true},
+		{"/^audit\\..*_log$/", "public", "login_log", false},
+		{"/[/", "public", "login_log", false},
+	}
+
+	for i, c := range cases {
+		if MatchTable(c.pattern, c.schema, c.table) != c.expected {
+			t.Errorf("%d) Expected %s to match %s.%s = %t", i, c.pattern, c.schema, c.table, c.expected)
+		}
+	}
+}
+
+func TestIsExcluded(t *testing.T) {
+	var cfg Config
+	cfg.Generator.ExcludedTables = []string{"public.excluded", "spatial_ref_sys"}
+	cfg.Generator.ExcludedColumns = []struct {
+		Tablename string   `yaml:"table"`
+		Columns   []string `yaml:"columns"`
+	}{
+		{Tablename: "test_schema.user", Columns: []string{"geog"}},
+		{Tablename: "*.*", Columns: []string{"*_hash"}},
+	}
+
+	if !cfg.IsTableExcluded("public", "excluded") {
+		t.Error("Expected public.excluded to be excluded")
+	}
+
+	if cfg.IsTableExcluded("test_schema", "excluded") {
+		t.Error("Expected test_schema.excluded to be included")
+	}
+
+	if !cfg.IsTableExcluded("test_schema", "spatial_ref_sys") {
+		t.Error("Expected spatial_ref_sys to be excluded from every schema")
+	}
+
+	cfg.Generator.IncludedTables = []string{"test_schema.*"}
+	if !cfg.IsTableExcluded("public", "foo") {
+		t.Error("Expected public.foo to be excluded when not in included_tables")
+	}
+
+	if cfg.IsTableExcluded("test_schema", "foo") {
+		t.Error("Expected test_schema.foo to be included")
+	}
+
+	if !cfg.IsColumnExcluded("test_schema", "user", "geog") || !cfg.IsColumnExcluded("test_schema", "user", "pword_hash") {
+		t.Error("Expected geog and pword_hash to be excluded")
+	}
+
+	if cfg.IsColumnExcluded("public", "user", "geog") || cfg.IsColumnExcluded("test_schema", "user", "email") {
+		t.Error("Expected the column to be included")
+	}
+
+	if !cfg.IsColumnExcluded("public", "account", "pword_hash") {
+		t.Error("Expected pword_hash to be excluded from every table")
+	}
+}
@@ -0,0 +1,63 @@
+package dbmap
+
+import (
+	"fmt"
+)
+
+// ConnectError A failure to connect to the database. The data source has its password hidden
+type ConnectError struct {
+	DataSource string
+	Err        error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("connecting to %s: %s", e.DataSource, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// ReadError A failure reading the catalog of the database. Op is what was being read (tables, columns, indexes,
+// foreign relationships or types), and Schema and Table are set when the failure is specific to them
+type ReadError struct {
+	Schema string
+	Table  string
+	Op     string
+	Err    error
+}
+
+func (e *ReadError) Error() string {
+	name := e.Schema
+	if e.Table != "" {
+		name += "." + e.Table
+	}
+	if name == "" {
+		return fmt.Sprintf("reading %s: %s", e.Op, e.Err)
+	}
+	return fmt.Sprintf("reading %s of %s: %s", e.Op, name, e.Err)
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateError A failure generating the file of a table. Table is empty when the directory of the schema could not be
+// created
+type GenerateError struct {
+	Schema string
+	Table  string
+	Path   string
+	Err    error
+}
+
+func (e *GenerateError) Error() string {
+	if e.Table == "" {
+		return fmt.Sprintf("generating %s for %s: %s", e.Path, e.Schema, e.Err)
+	}
+	return fmt.Sprintf("generating %s for %s.%s: %s", e.Path, e.Schema, e.Table, e.Err)
+}
+
+func (e *GenerateError) Unwrap() error {
+	return e.Err
+}
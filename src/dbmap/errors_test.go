@@ -0,0 +1,56 @@
+package dbmap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadError(t *testing.T) {
+	cause := errors.New("permission denied")
+	cases := []struct {
+		err      *ReadError
+		expected string
+	}{
+		{&ReadError{Op: "domains", Err: cause}, "reading domains: permission denied"},
+		{&ReadError{Schema: "test_schema", Op: "indexes", Err: cause}, "reading indexes of test_schema: permission denied"},
+		{&ReadError{Schema: "test_schema", Table: "user", Op: "columns", Err: cause},
+			"reading columns of test_schema.user: permission denied"},
+	}
+
+	for i, c := range cases {
+		if c.err.Error() != c.expected {
+			t.Errorf("%d) Expected %s but got %s", i, c.expected, c.err)
+		}
+		if !errors.Is(c.err, cause) {
+			t.Errorf("%d) Expected the error to wrap its cause", i)
+		}
+	}
+}
+
+type failingFileSystem struct {
+	MemoryFileSystem
+}
+
+func (*failingFileSystem) MkdirAll(path string) error {
+	return errors.New("read-only file system")
+}
+
+func TestGenerateProtoError(t *testing.T) {
+	var cfg Config
+	cfg.Proto.Path = "protos"
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{{TableSchema: "test_schema", TableName: "user"}}}}}
+
+	err := GenerateProtoTo(&failingFileSystem{}, cfg, database)
+	var generateErr *GenerateError
+	if !errors.As(err, &generateErr) || generateErr.Schema != "test_schema" {
+		t.Fatalf("Expected a GenerateError for test_schema but got %v", err)
+	}
+
+	fs := NewMemoryFileSystem()
+	if err := GenerateProtoTo(fs, cfg, database); err != nil {
+		t.Fatal(err)
+	}
+	if names := fs.Names(); len(names) != 1 || names[0] != "protos/test_schema/user.proto" {
+		t.Errorf("Expected the proto to be written in memory but got %v", names)
+	}
+}
@@ -0,0 +1,131 @@
+package dbmap
+
+import (
+	"context"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"sync"
+)
+
+// Generator Produces output files from the database, such as the protos or the code. A generator is selected by its
+// name in output.generators, and writes its files to the FileSystem so that it can be run into memory by check
+type Generator interface {
+	Name() string
+	Generate(ctx context.Context, cfg Config, database *Database, fs FileSystem) error
+}
+
+// GeneratorConfig An entry of output.generators. It is either the name of a registered generator, or an external
+// executable that is sent the model as JSON on stdin and replies with the files to write. The command defaults to
+// go_dbmap-gen-<name> found on the PATH, and the files are written under path, which defaults to output.path. An entry
+// can be written as just its name
+type GeneratorConfig struct {
+	Name    string            `yaml:"name"`
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Path    string            `yaml:"path"`
+	Options map[string]string `yaml:"options"`
+}
+
+func (g *GeneratorConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		g.Name = value.Value
+		return nil
+	}
+
+	type plain GeneratorConfig
+	return value.Decode((*plain)(g))
+}
+
+// The generators run when output.generators is not given
+var defaultGenerators = []string{"proto", "code"}
+
+var (
+	generatorsMu sync.RWMutex
+	generators   = make(map[string]Generator)
+)
+
+func init() {
+	RegisterGenerator(protoGenerator{})
+	RegisterGenerator(codeGenerator{})
+}
+
+// RegisterGenerator Makes the generator available by its name to output.generators, replacing any generator with the
+// same name. A team can add its own output by registering a generator from the init of a package linked into go_dbmap
+func RegisterGenerator(generator Generator) {
+	generatorsMu.Lock()
+	defer generatorsMu.Unlock()
+	generators[generator.Name()] = generator
+}
+
+// LookupGenerator Returns the registered generator with the name
+func LookupGenerator(name string) (Generator, bool) {
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	generator, ok := generators[name]
+	return generator, ok
+}
+
+// RegisteredGenerators Returns the names of the registered generators, sorted
+func RegisteredGenerators() []string {
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generators Returns the generators of output.generators in order, or proto and code when none are given. An entry
+// with a command, or a name that is not registered, is an external executable
+func (cfg Config) Generators() []Generator {
+	entries := cfg.Output.Generators
+	if len(entries) == 0 {
+		for _, name := range defaultGenerators {
+			entries = append(entries, GeneratorConfig{Name: name})
+		}
+	}
+
+	selected := make([]Generator, 0, len(entries))
+	for _, entry := range entries {
+		if generator, ok := LookupGenerator(entry.Name); ok && entry.Command == "" {
+			selected = append(selected, generator)
+		} else {
+			selected = append(selected, ExecGenerator{entry})
+		}
+	}
+	return selected
+}
+
+// RunGenerators Runs every generator of the configuration, stopping at the first that fails
+func RunGenerators(ctx context.Context, cfg Config, database *Database, fs FileSystem) error {
+	for _, generator := range cfg.Generators() {
+		if err := generator.Generate(ctx, cfg, database, fs); err != nil {
+			return fmt.Errorf("generator %s: %w", generator.Name(), err)
+		}
+	}
+	return nil
+}
+
+type protoGenerator struct{}
+
+func (protoGenerator) Name() string {
+	return "proto"
+}
+
+func (protoGenerator) Generate(ctx context.Context, cfg Config, database *Database, fs FileSystem) error {
+	return GenerateProtoTo(fs, cfg, database)
+}
+
+type codeGenerator struct{}
+
+func (codeGenerator) Name() string {
+	return "code"
+}
+
+func (codeGenerator) Generate(ctx context.Context, cfg Config, database *Database, fs FileSystem) error {
+	return GenerateCodeTo(fs, cfg, database)
+}
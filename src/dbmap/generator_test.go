@@ -0,0 +1,97 @@
+package dbmap
+
+import (
+	"context"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"testing"
+)
+
+type tableListGenerator struct{}
+
+func (tableListGenerator) Name() string {
+	return "test"
+}
+
+func (tableListGenerator) Generate(ctx context.Context, cfg Config, database *Database, fs FileSystem) error {
+	return writeFile(fs, cfg.Output.Path+"/tables.txt", database.Schemas[0].Tables[0].TableName+"\n")
+}
+
+func TestGenerators(t *testing.T) {
+	RegisterGenerator(tableListGenerator{})
+
+	var cfg Config
+	if names := generatorNames(cfg.Generators()); names != "proto,code" {
+		t.Errorf("Expected the default generators but got %s", names)
+	}
+
+	config := `
+generators:
+  - test
+  - name: "repository"
+    command: "./bin/gen-repository"
+    options:
+      package: "repo"
+`
+	if err := yaml.Unmarshal([]byte(config), &cfg.Output); err != nil {
+		t.Fatal(err)
+	}
+	generators := cfg.Generators()
+	if names := generatorNames(generators); names != "test,repository" {
+		t.Fatalf("Expected the configured generators but got %s", names)
+	}
+	if exec, ok := generators[1].(ExecGenerator); !ok || exec.Options["package"] != "repo" {
+		t.Errorf("Expected an external generator but got %+v", generators[1])
+	}
+
+	cfg.Output.Path = "out"
+	cfg.Output.Generators = cfg.Output.Generators[:1]
+	fs := NewMemoryFileSystem()
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{{TableName: "user"}}}}}
+	if err := RunGenerators(context.Background(), cfg, database, fs); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Files["out/tables.txt"].String() != "user\n" {
+		t.Errorf("Expected the test generator to write its file but got %v", fs.Names())
+	}
+}
+
+func TestExecGenerator(t *testing.T) {
+	// The plugin writes whether it was sent the password
+	script := `read -r request
+case "$request" in *secret*) leaked=true ;; *) leaked=false ;; esac
+echo '{"files": [{"name": "sub/generated.txt", "content": "'$leaked'"}]}'`
+
+	var cfg Config
+	cfg.Database.Password = "secret"
+	cfg.Output.Path = "out"
+	g := ExecGenerator{GeneratorConfig{Name: "shell", Command: "sh", Args: []string{"-c", script}}}
+
+	fs := NewMemoryFileSystem()
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema"}}}
+	if err := g.Generate(context.Background(), cfg, database, fs); err != nil {
+		t.Fatal(err)
+	}
+	if content := fs.Files["out/sub/generated.txt"]; content == nil || content.String() != "false" {
+		t.Errorf("Expected the file without the password but got %v", fs.Names())
+	}
+
+	g.Args = []string{"-c", `echo '{"files": [{"name": "../escape.txt", "content": ""}]}'`}
+	if err := g.Generate(context.Background(), cfg, database, fs); err == nil ||
+		!strings.Contains(err.Error(), "not within out") {
+		t.Errorf("Expected a file outside of the path to be rejected but got %v", err)
+	}
+
+	g.Args = []string{"-c", `echo '{"error": "no tables"}'`}
+	if err := g.Generate(context.Background(), cfg, database, fs); err == nil || err.Error() != "no tables" {
+		t.Errorf("Expected the error of the plugin but got %v", err)
+	}
+}
+
+func generatorNames(generators []Generator) string {
+	names := make([]string, 0)
+	for _, generator := range generators {
+		names = append(names, generator.Name())
+	}
+	return strings.Join(names, ",")
+}
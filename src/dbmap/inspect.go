@@ -0,0 +1,76 @@
+package dbmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+var indexTypes = map[IndexType]string{
+	PrimaryKey: "PRIMARY KEY",
+	Unique:     "UNIQUE",
+	NonUnique:  "INDEX",
+}
+
+// Inspect Writes the introspected model as a table per database table, with its columns, indexes and foreign
+// relationships
+func Inspect(w io.Writer, database *Database) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, schema := range database.Schemas {
+		for _, table := range schema.Tables {
+			_, _ = fmt.Fprintf(tw, "%s.%s\n", table.TableSchema, table.TableName)
+			_, _ = fmt.Fprintf(tw, "  COLUMN\tTYPE\tNULLABLE\tKEY\tDEFAULT\n")
+			for _, column := range table.Columns {
+				_, _ = fmt.Fprintf(tw, "  %s\t%s\t%t\t%s\t%s\n", column.ColumnName, inspectType(column),
+					column.IsNullable, inspectKey(column), column.ColumnDefault)
+			}
+
+			for _, index := range table.Indexes {
+				_, _ = fmt.Fprintf(tw, "  %s\t%s\t(%s)\t%s\t%s\n", indexTypes[index.IndexType], index.IndexName,
+					strings.Join(index.Columns, ", "), index.Method, index.Predicate)
+			}
+
+			for _, relation := range table.Relations {
+				_, _ = fmt.Fprintf(tw, "  FOREIGN KEY\t(%s)\t=> %s.%s\t\t\n", getLocalKeys(&relation),
+					relation.ForeignSchema, relation.ForeignTable)
+			}
+			_, _ = fmt.Fprintln(tw)
+		}
+	}
+	return tw.Flush()
+}
+
+// InspectJSON Writes the introspected model as JSON
+func InspectJSON(w io.Writer, database *Database) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(database)
+}
+
+func inspectType(column Column) string {
+	udtName := column.UdtName
+	if column.Composite != nil {
+		udtName = column.Composite.TypeSchema + "." + column.Composite.TypeName
+		if column.DataType == "ARRAY" {
+			udtName += "[]"
+		}
+	}
+
+	if column.Domain != nil {
+		return fmt.Sprintf("%s.%s (%s)", column.Domain.DomainSchema, column.Domain.DomainName, udtName)
+	}
+	return udtName
+}
+
+func inspectKey(column Column) string {
+	if column.IsPrimaryKey && column.IsSequence {
+		return "PK SEQ"
+	} else if column.IsPrimaryKey {
+		return "PK"
+	} else if column.IsSequence {
+		return "SEQ"
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package dbmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func inspectDatabase() *Database {
+	table := Table{TableSchema: "test_schema", TableName: "user",
+		Columns: []Column{
+			{TableSchema: "test_schema", TableName: "user", ColumnName: "user_id", UdtName: "int8", IsPrimaryKey: true,
+				IsSequence: true},
+			{TableSchema: "test_schema", TableName: "user", ColumnName: "email", UdtName: "varchar", IsNullable: true},
+		},
+		Indexes: []Index{{TableSchema: "test_schema", TableName: "user", IndexName: "user_email_idx", IndexType: Unique,
+			Columns: []string{"email"}, Method: "btree"}},
+	}
+	return &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{table}}}}
+}
+
+func TestInspect(t *testing.T) {
+	var b bytes.Buffer
+	if err := Inspect(&b, inspectDatabase()); err != nil {
+		t.Fatal(err)
+	}
+
+	out := b.String()
+	for _, expected := range []string{"test_schema.user", "user_id", "PK SEQ", "UNIQUE", "user_email_idx", "(email)", "btree"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %s in\n%s", expected, out)
+		}
+	}
+}
+
+func TestInspectJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := InspectJSON(&b, inspectDatabase()); err != nil {
+		t.Fatal(err)
+	}
+
+	var database Database
+	if err := json.Unmarshal(b.Bytes(), &database); err != nil {
+		t.Fatal(err)
+	}
+	if len(database.Schemas) != 1 || database.Schemas[0].Tables[0].Indexes[0].IndexName != "user_email_idx" {
+		t.Errorf("Unexpected model %+v", database)
+	}
+}
@@ -1,11 +1,10 @@
 package mariadb
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"github.com/bryanhughes/go_dbmap/src/dbmap"
 	_ "github.com/lib/pq"
-	"log"
 )
 
 const selectTables = "SELECT * FROM information_schema.tables WHERE table_schema = $1 AND table_name NOT IN ($2)"
@@ -64,11 +63,14 @@ type Provider struct {
 	dbmap.Config
 }
 
-func (provider *Provider) ReadDatabase() *dbmap.Database {
+func (provider *Provider) ReadDatabase(ctx context.Context) (*dbmap.Database, error) {
 	schemaNames := provider.Generator.Schemas
 	schemas := make([]dbmap.Schema, len(schemaNames))
 
-	db := initDB(provider)
+	db, err := initDB(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
 
 	for i, schemaName := range schemaNames {
 		schema := dbmap.Schema{SchemaName: schemaName}
@@ -77,30 +79,25 @@ func (provider *Provider) ReadDatabase() *dbmap.Database {
 	}
 
 	database := dbmap.Database{DB: db, Schemas: schemas}
-	return &database
+	return &database, nil
 }
 
-func initDB(provider *Provider) *sql.DB {
-	fmt.Printf("Connecting to %s://user=%s:%s/%s\n",
-		provider.Database.Provider, provider.Database.Username, provider.Database.Host, provider.Database.Database)
-
-	dataSource := "host=" + provider.Database.Host + " port=" + provider.Database.Port +
-		" user=" + provider.Database.Username + " password=" + provider.Database.Password +
-		" dbname=" + provider.Database.Database + " sslmode=disable"
+func initDB(ctx context.Context, provider *Provider) (*sql.DB, error) {
+	provider.Logf("Connecting to %s: %s\n", provider.Database.Provider, provider.RedactedDataSource())
 
-	var err error
-	db, err := sql.Open("postgres", dataSource)
+	db, err := sql.Open("postgres", provider.DataSource())
 	if err != nil {
-		log.Panic(err)
+		return nil, &dbmap.ConnectError{DataSource: provider.RedactedDataSource(), Err: err}
 	}
 
-	if err = db.Ping(); err != nil {
-		log.Panic(err)
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, &dbmap.ConnectError{DataSource: provider.RedactedDataSource(), Err: err}
 	}
 
-	return db
+	return db, nil
 }
 
 func readSchema(provider *Provider, schema *dbmap.Schema) {
-	fmt.Printf("[%s] %s\n", provider.Database.Provider, schema.SchemaName)
+	provider.Logf("[%s] %s\n", provider.Database.Provider, schema.SchemaName)
 }
@@ -0,0 +1,62 @@
+package dbmap
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+)
+
+// FileSystem Where the generated files are written, so that they can be generated into memory as well as to disk
+type FileSystem interface {
+	MkdirAll(path string) error
+	Create(name string) (io.WriteCloser, error)
+}
+
+// DiskFileSystem Writes the generated files to disk, relative to the working directory
+type DiskFileSystem struct{}
+
+func (DiskFileSystem) MkdirAll(path string) error {
+	return os.MkdirAll(path, os.ModePerm)
+}
+
+func (DiskFileSystem) Create(name string) (io.WriteCloser, error) {
+	return os.Create(name)
+}
+
+// MemoryFileSystem Holds the generated files in memory, keyed by their path
+type MemoryFileSystem struct {
+	Files map[string]*bytes.Buffer
+}
+
+func NewMemoryFileSystem() *MemoryFileSystem {
+	return &MemoryFileSystem{Files: make(map[string]*bytes.Buffer)}
+}
+
+func (m *MemoryFileSystem) MkdirAll(path string) error {
+	return nil
+}
+
+func (m *MemoryFileSystem) Create(name string) (io.WriteCloser, error) {
+	b := &bytes.Buffer{}
+	m.Files[name] = b
+	return memoryFile{b}, nil
+}
+
+// Names Returns the path of every file, sorted
+func (m *MemoryFileSystem) Names() []string {
+	names := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type memoryFile struct {
+	*bytes.Buffer
+}
+
+func (memoryFile) Close() error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package dbmap
+
+import (
+	"fmt"
+	"github.com/iancoleman/strcase"
+	"strings"
+)
+
+// The operations generated for a table when generator.tables does not limit them
+var Operations = []string{"create", "read", "update", "delete"}
+
+// TableOverride An entry of generator.tables, which changes how the matching tables are generated. The table accepts
+// the same patterns as excluded_tables and the first matching entry is used. Operations limits the CRUD operations
+// that are generated, so ["read"] makes the table read only. OrderBy names the unique index that the generated List
+// function orders and pages by instead of the primary key. UpsertColumns are the columns that the generated upserts
+// overwrite when the row exists, which are all the written columns when it is not set. VersionColumn names an integer
+// or timestamp column that the generated Update and Delete check and move forward, for optimistic concurrency
+type TableOverride struct {
+	Tablename     string           `yaml:"table"`
+	GoName        string           `yaml:"go_name"`
+	Message       string           `yaml:"message"`
+	Operations    []string         `yaml:"operations"`
+	OrderBy       string           `yaml:"order_by"`
+	UpsertColumns []string         `yaml:"upsert_columns"`
+	VersionColumn string           `yaml:"version_column"`
+	Columns       []ColumnOverride `yaml:"columns"`
+}
+
+// ColumnOverride Changes how a column is generated. GoType replaces the type of the field in the Go code, which is
+// written with its import path, such as github.com/shopspring/decimal.Decimal for a numeric, while ProtoType replaces
+// the scalar type of the proto field. JSON is the name of the field in JSON, which is written as the json_name of the
+// proto field
+type ColumnOverride struct {
+	Columnname string `yaml:"column"`
+	GoName     string `yaml:"go_name"`
+	Field      string `yaml:"field"`
+	GoType     string `yaml:"go_type"`
+	ProtoType  string `yaml:"proto_type"`
+	JSON       string `yaml:"json"`
+}
+
+// TableOverride Returns the first entry of generator.tables matching the table, or an empty override
+func (cfg Config) TableOverride(schemaName string, tableName string) TableOverride {
+	for _, override := range cfg.Generator.Tables {
+		if MatchTable(override.Tablename, schemaName, tableName) {
+			return override
+		}
+	}
+	return TableOverride{}
+}
+
+// ColumnOverride Returns the override of the column from the first entry of generator.tables matching the table
+func (cfg Config) ColumnOverride(schemaName string, tableName string, columnName string) ColumnOverride {
+	for _, override := range cfg.TableOverride(schemaName, tableName).Columns {
+		if override.Columnname == columnName {
+			return override
+		}
+	}
+	return ColumnOverride{}
+}
+
+// MessageName Returns the name of the proto message of the table
+func (cfg Config) MessageName(schemaName string, tableName string) string {
+	if message := cfg.TableOverride(schemaName, tableName).Message; message != "" {
+		return message
+	}
+	return strcase.ToCamel(tableName)
+}
+
+// GoName Returns the name of the Go type of the table, which is the message name unless it is overridden
+func (cfg Config) GoName(schemaName string, tableName string) string {
+	if goName := cfg.TableOverride(schemaName, tableName).GoName; goName != "" {
+		return goName
+	}
+	return cfg.MessageName(schemaName, tableName)
+}
+
+// FieldName Returns the name of the proto field of the column
+func (cfg Config) FieldName(column Column) string {
+	if field := cfg.ColumnOverride(column.TableSchema, column.TableName, column.ColumnName).Field; field != "" {
+		return field
+	}
+	return column.ColumnName
+}
+
+// GoFieldName Returns the name of the Go field of the column
+func (cfg Config) GoFieldName(column Column) string {
+	if goName := cfg.ColumnOverride(column.TableSchema, column.TableName, column.ColumnName).GoName; goName != "" {
+		return goName
+	}
+	return strcase.ToCamel(cfg.FieldName(column))
+}
+
+// JSONName Returns the name of the column in JSON, which is the lower camel case field name unless it is overridden
+func (cfg Config) JSONName(column Column) string {
+	if json := cfg.ColumnOverride(column.TableSchema, column.TableName, column.ColumnName).JSON; json != "" {
+		return json
+	}
+	return strcase.ToLowerCamel(cfg.FieldName(column))
+}
+
+// GoType Returns the overridden Go type of the column, or an empty string when the generator chooses the type
+func (cfg Config) GoType(column Column) string {
+	return cfg.ColumnOverride(column.TableSchema, column.TableName, column.ColumnName).GoType
+}
+
+// GeneratesOperation Returns true if the operation (create, read, update or delete) is generated for the table
+func (cfg Config) GeneratesOperation(schemaName string, tableName string, operation string) bool {
+	operations := cfg.TableOverride(schemaName, tableName).Operations
+	if operations == nil {
+		operations = Operations
+	}
+	for _, o := range operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// ListOrder Returns the columns that the generated List function of the table orders by, which are also the key of its
+// cursor. These are the columns of the unique index named by order_by, or else of the primary key. A table without
+// either cannot be paged, since the order of its rows would not be stable
+func (cfg Config) ListOrder(table Table) ([]string, error) {
+	if orderBy := cfg.TableOverride(table.TableSchema, table.TableName).OrderBy; orderBy != "" {
+		for _, index := range table.Indexes {
+			if index.IndexName != orderBy {
+				continue
+			}
+			if index.IndexType == NonUnique || len(index.Expressions) > 0 || index.Predicate != "" {
+				return nil, fmt.Errorf("%s of %s.%s must be a unique index of columns without a predicate", orderBy,
+					table.TableSchema, table.TableName)
+			}
+			return index.Columns, nil
+		}
+		return nil, fmt.Errorf("%s.%s has no index %s", table.TableSchema, table.TableName, orderBy)
+	}
+
+	for _, index := range table.Indexes {
+		if index.IndexType == PrimaryKey {
+			return index.Columns, nil
+		}
+	}
+
+	var columns []string
+	for _, column := range table.Columns {
+		if column.IsPrimaryKey {
+			columns = append(columns, column.ColumnName)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%s.%s has no primary key, name a unique index with order_by", table.TableSchema,
+			table.TableName)
+	}
+	return columns, nil
+}
+
+// VersionColumn Returns the version column of the table named by version_column, and false when the table does not
+// have one
+func (cfg Config) VersionColumn(table Table) (Column, bool) {
+	name := cfg.TableOverride(table.TableSchema, table.TableName).VersionColumn
+	if name == "" {
+		return Column{}, false
+	}
+	for _, column := range table.Columns {
+		if column.ColumnName == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// IsVersionType Returns true if the column can be a version column, which is an integer that is incremented or a
+// timestamp that is set to the time of the update
+func IsVersionType(column Column) bool {
+	switch column.UdtName {
+	case "int2", "int4", "int8", "smallint", "integer", "bigint":
+		return true
+	}
+	return strings.HasPrefix(column.UdtName, "timestamp")
+}
+
+// VersionSet Returns the assignment that moves the version column forward on every update. A timestamp is set with
+// clock_timestamp(), since now() is the same for every statement of a transaction. The qualifier is the table that
+// the current version is read from, which an upsert needs to tell the existing row from the excluded one.
+func VersionSet(column Column, qualifier string) string {
+	if strings.HasPrefix(column.UdtName, "timestamp") {
+		return column.ColumnName + " = clock_timestamp()"
+	}
+	current := column.ColumnName
+	if qualifier != "" {
+		current = qualifier + "." + current
+	}
+	return column.ColumnName + " = " + current + " + 1"
+}
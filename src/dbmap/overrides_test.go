@@ -0,0 +1,220 @@
+package dbmap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func overrideConfig() Config {
+	var cfg Config
+	cfg.Proto.Version = "proto3"
+	cfg.Generator.Tables = []TableOverride{
+		{Tablename: "test_schema.tbl_usr_acct", Message: "UserAccount", Operations: []string{"read"},
+			Columns: []ColumnOverride{
+				{Columnname: "usr_nm", Field: "username", JSON: "userName"},
+				{Columnname: "balance", GoType: "decimal.Decimal", ProtoType: "string"},
+			}},
+		{Tablename: "*.tbl_*", GoName: "Legacy"},
+	}
+	return cfg
+}
+
+func TestOverrides(t *testing.T) {
+	cfg := overrideConfig()
+	name := Column{TableSchema: "test_schema", TableName: "tbl_usr_acct", ColumnName: "usr_nm"}
+	balance := Column{TableSchema: "test_schema", TableName: "tbl_usr_acct", ColumnName: "balance"}
+
+	cases := []struct {
+		actual   string
+		expected string
+	}{
+		{cfg.MessageName("test_schema", "tbl_usr_acct"), "UserAccount"},
+		{cfg.GoName("test_schema", "tbl_usr_acct"), "UserAccount"},
+		{cfg.MessageName("public", "tbl_usr_acct"), "TblUsrAcct"},
+		{cfg.GoName("public", "tbl_usr_acct"), "Legacy"},
+		{cfg.MessageName("test_schema", "user"), "User"},
+		{cfg.FieldName(name), "username"},
+		{cfg.GoFieldName(name), "Username"},
+		{cfg.JSONName(name), "userName"},
+		{cfg.FieldName(balance), "balance"},
+		{cfg.JSONName(balance), "balance"},
+		{cfg.GoType(balance), "decimal.Decimal"},
+		{cfg.GoType(name), ""},
+	}
+	for i, c := range cases {
+		if c.actual != c.expected {
+			t.Errorf("%d) Expected %s but got %s", i, c.expected, c.actual)
+		}
+	}
+
+	if !cfg.GeneratesOperation("test_schema", "tbl_usr_acct", "read") ||
+		cfg.GeneratesOperation("test_schema", "tbl_usr_acct", "update") {
+		t.Error("Expected test_schema.tbl_usr_acct to be read only")
+	}
+	if !cfg.GeneratesOperation("public", "tbl_usr_acct", "delete") {
+		t.Error("Expected every operation when the override does not list any")
+	}
+}
+
+func TestWriteFieldOverrides(t *testing.T) {
+	cfg := overrideConfig()
+	table := Table{TableSchema: "test_schema", TableName: "tbl_usr_acct", Columns: []Column{
+		{TableSchema: "test_schema", TableName: "tbl_usr_acct", ColumnName: "usr_nm", UdtName: "text"},
+		{TableSchema: "test_schema", TableName: "tbl_usr_acct", ColumnName: "balance", UdtName: "numeric"},
+	}}
+
+	expected := "    string username = 1 [json_name = \"userName\"];\n" +
+		"    string balance = 2;\n"
+	var b strings.Builder
+	writeFields(&b, cfg, table)
+	if b.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, b.String())
+	}
+}
+
+func TestValidateOverrides(t *testing.T) {
+	config := validConfig + `  tables:
+    -
+      table: "test_schema.user"
+      message: "Account"
+      operations: ["read", "upsert"]
+      columns:
+        -
+          column: "email"
+          field: "e-mail"
+        -
+          column: "missing"
+    -
+      go_name: "Thing"
+`
+	var cfg Config
+	if err := ReadFile(&cfg, writeConfig(t, config)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`line 39: generator.tables.0.operations.1: unknown operation "upsert", must be one of create, read, update, ` +
+			"delete",
+		`line 43: generator.tables.0.columns.0.field: "e-mail" is not a valid name`,
+		"line 47: generator.tables.1.table: must have a value",
+	}
+	problems := cfg.Validate()
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems but got %v", len(expected), problems)
+	}
+	for i, problem := range problems {
+		if problem.Error() != expected[i] {
+			t.Errorf("%d) Expected %s but got %s", i, expected[i], problem)
+		}
+	}
+
+	table := Table{TableSchema: "test_schema", TableName: "user", Columns: []Column{{ColumnName: "geog"},
+		{ColumnName: "lon"}, {ColumnName: "lat"}, {ColumnName: "email"}}}
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{table}}}}
+
+	expectedDatabase := "line 45: generator.tables.0.columns.1.column: test_schema.user has no column missing"
+	problems = cfg.ValidateDatabase(database)
+	if len(problems) != 1 || problems[0].Error() != expectedDatabase {
+		t.Errorf("Expected %s but got %v", expectedDatabase, problems)
+	}
+}
+
+func TestListOrder(t *testing.T) {
+	var cfg Config
+	cfg.Generator.Tables = []TableOverride{
+		{Tablename: "test_schema.user", OrderBy: "lookup_email"},
+		{Tablename: "test_schema.account", OrderBy: "idx_account_name"},
+	}
+
+	user := Table{TableSchema: "test_schema", TableName: "user", Indexes: []Index{
+		{IndexName: "pk_user", IndexType: PrimaryKey, Columns: []string{"user_id"}},
+		{IndexName: "lookup_email", IndexType: Unique, Columns: []string{"email"}},
+	}}
+	if columns, err := cfg.ListOrder(user); err != nil || !reflect.DeepEqual(columns, []string{"email"}) {
+		t.Errorf("Expected to order by email but got %v - %v", columns, err)
+	}
+
+	user.TableSchema = "public"
+	if columns, err := cfg.ListOrder(user); err != nil || !reflect.DeepEqual(columns, []string{"user_id"}) {
+		t.Errorf("Expected to order by the primary key but got %v - %v", columns, err)
+	}
+
+	part := Table{TableSchema: "public", TableName: "part", Columns: []Column{
+		{ColumnName: "part_id", IsPrimaryKey: true}, {ColumnName: "part_name"}, {ColumnName: "version", IsPrimaryKey: true},
+	}}
+	if columns, err := cfg.ListOrder(part); err != nil || !reflect.DeepEqual(columns, []string{"part_id", "version"}) {
+		t.Errorf("Expected to order by the primary key columns but got %v - %v", columns, err)
+	}
+
+	part.Columns = part.Columns[1:2]
+	if _, err := cfg.ListOrder(part); err == nil {
+		t.Error("Expected an error for a table without a primary key")
+	}
+
+	account := Table{TableSchema: "test_schema", TableName: "account", Indexes: []Index{
+		{IndexName: "idx_account_name", IndexType: NonUnique, Columns: []string{"name"}},
+	}}
+	if _, err := cfg.ListOrder(account); err == nil {
+		t.Error("Expected an error for an index that is not unique")
+	}
+
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{account}}}}
+	problems := cfg.ValidateDatabase(database)
+	if len(problems) != 2 || !strings.Contains(problems[1].Error(), "must be a unique index") {
+		t.Errorf("Expected test_schema.user to be missing and idx_account_name not to be unique but got %v", problems)
+	}
+}
+
+func TestVersionColumn(t *testing.T) {
+	var cfg Config
+	cfg.Generator.Tables = []TableOverride{
+		{Tablename: "test_schema.user", VersionColumn: "version"},
+		{Tablename: "test_schema.address", VersionColumn: "updated_at"},
+		{Tablename: "test_schema.account", VersionColumn: "name"},
+		{Tablename: "test_schema.part", VersionColumn: "missing"},
+	}
+
+	user := Table{TableSchema: "test_schema", TableName: "user", Columns: []Column{
+		{ColumnName: "user_id", UdtName: "int4", IsPrimaryKey: true, IsSequence: true},
+		{ColumnName: "email", UdtName: "varchar"},
+		{ColumnName: "version", UdtName: "int4"},
+	}, Indexes: []Index{{IndexName: "lookup_email", IndexType: Unique, Columns: []string{"email"}}}}
+	address := Table{TableSchema: "test_schema", TableName: "address", Columns: []Column{
+		{ColumnName: "updated_at", UdtName: "timestamptz"},
+	}}
+	account := Table{TableSchema: "test_schema", TableName: "account", Columns: []Column{
+		{ColumnName: "name", UdtName: "text"},
+	}}
+	part := Table{TableSchema: "test_schema", TableName: "part"}
+
+	version, ok := cfg.VersionColumn(user)
+	if !ok || VersionSet(version, "") != "version = version + 1" {
+		t.Errorf("Expected the version to be incremented but got %v", version)
+	}
+	if set := VersionSet(version, "test_schema.user"); set != "version = test_schema.user.version + 1" {
+		t.Errorf("Expected the qualified version but got %s", set)
+	}
+	if updatedAt, _ := cfg.VersionColumn(address); VersionSet(updatedAt, "") != "updated_at = clock_timestamp()" {
+		t.Errorf("Expected updated_at to be set to the time of the update but got %v", updatedAt)
+	}
+
+	columns := cfg.UpsertColumns(user, user.Indexes[0])
+	if len(columns) != 0 {
+		t.Errorf("Expected the version not to be overwritten but got %v", columns)
+	}
+	expected := "ON CONFLICT (email) DO UPDATE SET version = test_schema.user.version + 1"
+	if clause := UpsertClause(user.Indexes[0], columns, VersionSet(version, "test_schema.user")); clause != expected {
+		t.Errorf("Expected %s but got %s", expected, clause)
+	}
+	if masked := cfg.MaskColumns(user); len(masked) != 1 || masked[0].Column != "email" {
+		t.Errorf("Expected only email to be maskable but got %v", masked)
+	}
+
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{user, address, account, part}}}}
+	problems := cfg.ValidateDatabase(database)
+	if len(problems) != 2 || !strings.Contains(problems[0].Error(), "name of test_schema.account must be an integer") ||
+		!strings.Contains(problems[1].Error(), "test_schema.part has no column missing") {
+		t.Errorf("Expected the text and missing version columns to be reported but got %v", problems)
+	}
+}
@@ -0,0 +1,108 @@
+package dbmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PluginRequest What an external generator is sent as JSON on stdin. The database section of the configuration is
+// removed, so that the credentials are never passed on
+type PluginRequest struct {
+	Generator string            `json:"generator"`
+	Options   map[string]string `json:"options,omitempty"`
+	Config    Config            `json:"config"`
+	Database  *Database         `json:"database"`
+}
+
+// PluginResponse What an external generator replies with as JSON on stdout. The names of the files are relative to
+// the path of the generator. A generator that fails sets Error, or exits with a non-zero status
+type PluginResponse struct {
+	Files []PluginFile `json:"files"`
+	Error string       `json:"error,omitempty"`
+}
+
+type PluginFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// ExecGenerator A generator that is an external executable, similar to a protoc plugin
+type ExecGenerator struct {
+	GeneratorConfig
+}
+
+func (g ExecGenerator) Name() string {
+	return g.GeneratorConfig.Name
+}
+
+func (g ExecGenerator) Generate(ctx context.Context, cfg Config, database *Database, fs FileSystem) error {
+	command := g.Command
+	if command == "" {
+		command = "go_dbmap-gen-" + g.GeneratorConfig.Name
+	}
+
+	request := PluginRequest{Generator: g.GeneratorConfig.Name, Options: g.Options, Config: cfg, Database: database}
+	request.Config.Database = Config{}.Database
+	stdin, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, g.Args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", command, err)
+	}
+
+	var response PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return fmt.Errorf("reading the response of %s: %w", command, err)
+	}
+	if response.Error != "" {
+		return errors.New(response.Error)
+	}
+
+	dir := g.Path
+	if dir == "" {
+		dir = cfg.Output.Path
+	}
+	for _, file := range response.Files {
+		name := filepath.Clean(file.Name)
+		if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s returned the file %q, which is not within %s", command, file.Name, dir)
+		}
+
+		filename := filepath.Join(dir, name)
+		cfg.Logf("%s\n", filename)
+		if err := writeFile(fs, filename, file.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(fs FileSystem, filename string, content string) error {
+	if err := fs.MkdirAll(filepath.Dir(filename)); err != nil {
+		return err
+	}
+
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/bryanhughes/go_dbmap/src/dbmap"
+	"github.com/lib/pq"
+	"time"
+)
+
+// NotifyChannel The channel notified by the event trigger after every DDL command
+const NotifyChannel = "go_dbmap_ddl"
+
+// Event triggers can only be created by a superuser. The function is replaced and the trigger recreated so that
+// installing it again is harmless
+const installEventTrigger = `CREATE OR REPLACE FUNCTION public.go_dbmap_notify_ddl() RETURNS event_trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	PERFORM pg_notify('` + NotifyChannel + `', tg_tag);
+END
+$$;
+DROP EVENT TRIGGER IF EXISTS go_dbmap_ddl;
+CREATE EVENT TRIGGER go_dbmap_ddl ON ddl_command_end EXECUTE PROCEDURE public.go_dbmap_notify_ddl();`
+
+// InstallEventTrigger Installs an event trigger that notifies NotifyChannel after every DDL command, such as the
+// statements of a migration. It must be run as a superuser
+func (provider *Provider) InstallEventTrigger(ctx context.Context) error {
+	db, err := provider.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, installEventTrigger); err != nil {
+		return fmt.Errorf("failed to install the event trigger go_dbmap_ddl : %w", err)
+	}
+	provider.Logf("[%s] Installed event trigger go_dbmap_ddl\n", provider.Database.Provider)
+	return nil
+}
+
+// Listen Returns a channel that is signalled when the event trigger reports a DDL command. Several commands, such as
+// those of one migration, may be reported by a single signal. The channel is also signalled after reconnecting, since
+// notifications may have been missed. The listener is closed once ctx is done
+func (provider *Provider) Listen(ctx context.Context) (<-chan struct{}, error) {
+	listener := pq.NewListener(provider.DataSource(), time.Second, time.Minute, nil)
+	if err := listener.Listen(NotifyChannel); err != nil {
+		_ = listener.Close()
+		return nil, &dbmap.ConnectError{DataSource: provider.RedactedDataSource(), Err: err}
+	}
+	provider.Logf("[%s] Listening on %s\n", provider.Database.Provider, NotifyChannel)
+
+	wake := make(chan struct{}, 1)
+	go func() {
+		defer func() { _ = listener.Close() }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-listener.Notify:
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			case <-time.After(90 * time.Second):
+				go func() { _ = listener.Ping() }()
+			}
+		}
+	}()
+	return wake, nil
+}
@@ -1,17 +1,26 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/bryanhughes/go_dbmap/src/dbmap"
 	"github.com/lib/pq"
-	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
-const selectTables = "SELECT table_schema, table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND table_schema = $1 AND table_name NOT IN ($2) ORDER BY table_name"
+// Every schema with a table, other than the system schemas
+const selectSchemaNames = `SELECT DISTINCT table_schema FROM information_schema.tables
+	WHERE table_type = 'BASE TABLE' AND table_schema NOT IN ('pg_catalog', 'information_schema')
+	ORDER BY table_schema`
+
+const selectTables = "SELECT table_schema, table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND table_schema = $1 ORDER BY table_name"
 
 const selectColumns = `SELECT
+		c.table_name,
 		c.column_name, 
 		c.ordinal_position,
 		c.data_type,
@@ -19,16 +28,30 @@ const selectColumns = `SELECT
 		c.column_default,
 		CASE WHEN c.is_nullable is null THEN false ELSE true END is_nullable,
 		CASE WHEN pa.attname is null THEN false ELSE true END is_pkey,
-		CASE WHEN pg_get_serial_sequence(table_schema || '.' || table_name, column_name) is null THEN false ELSE true END is_seq
+		CASE WHEN pg_get_serial_sequence(table_schema || '.' || table_name, column_name) is null THEN false ELSE true END is_seq,
+		c.udt_schema,
+		c.udt_name,
+		c.domain_schema,
+		c.domain_name,
+		ut.typcategory = 'A' AS is_array,
+		ca.attndims,
+		COALESCE(en.nspname, ''),
+		COALESCE(et.typname, '')
 	 FROM
 		pg_namespace ns
 		JOIN pg_class t ON
 			t.relnamespace = ns.oid
 			AND t.relkind = 'r'
-			AND t.relname = $2
 		JOIN information_schema.columns c ON
 			c.table_schema = ns.nspname
 			AND c.table_name = t.relname
+		JOIN pg_attribute ca ON
+			ca.attrelid = t.oid
+			AND ca.attname = c.column_name
+		JOIN pg_type ct ON ct.oid = ca.atttypid
+		JOIN pg_type ut ON ut.oid = CASE WHEN ct.typtype = 'd' THEN ct.typbasetype ELSE ct.oid END
+		LEFT OUTER JOIN pg_type et ON et.oid = ut.typelem AND ut.typcategory = 'A'
+		LEFT OUTER JOIN pg_namespace en ON en.oid = et.typnamespace
 		LEFT OUTER JOIN pg_index pi ON
 			pi.indrelid = t.oid AND pi.indisprimary = true
 		LEFT OUTER JOIN pg_attribute pa ON
@@ -37,32 +60,91 @@ const selectColumns = `SELECT
 			AND pa.attname = c.column_name
 	 WHERE
 		ns.nspname = $1
-	 ORDER BY table_schema, table_name, ordinal_position`
+	 ORDER BY c.table_name, c.ordinal_position`
+
+// Every domain in the database along with its immediate base type, by its schema and name in the catalog. The base
+// type is also formatted the way information_schema reports the data type of a column. Domains over domains are
+// resolved by the provider
+const selectDomains = `SELECT
+		n.nspname,
+		t.typname,
+		bn.nspname,
+		bt.typname,
+		format_type(t.typbasetype, NULL),
+		bt.typtype,
+		bt.typcategory = 'A',
+		t.typnotnull,
+		t.typdefault,
+		ARRAY(SELECT pg_get_constraintdef(c.oid) FROM pg_constraint c WHERE c.contypid = t.oid ORDER BY c.conname)
+	 FROM
+		pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_type bt ON bt.oid = t.typbasetype
+		JOIN pg_namespace bn ON bn.oid = bt.typnamespace
+	 WHERE
+		t.typtype = 'd'
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	 ORDER BY n.nspname, t.typname`
+
+// Every attribute of every stand-alone composite type in the database (table row types are not included)
+const selectCompositeTypes = `SELECT
+		n.nspname,
+		t.typname,
+		a.attname,
+		a.attnum,
+		a.atttypid::regtype::text,
+		an.nspname,
+		at.typname,
+		at.typtype,
+		at.typcategory = 'A',
+		COALESCE(en.nspname, ''),
+		COALESCE(et.typname, '')
+	 FROM
+		pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_class c ON c.oid = t.typrelid AND c.relkind = 'c'
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		JOIN pg_type at ON at.oid = a.atttypid
+		JOIN pg_namespace an ON an.oid = at.typnamespace
+		LEFT OUTER JOIN pg_type et ON et.oid = at.typelem AND at.typcategory = 'A'
+		LEFT OUTER JOIN pg_namespace en ON en.oid = et.typnamespace
+	 WHERE
+		t.typtype = 'c'
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	 ORDER BY n.nspname, t.typname, a.attnum`
 
+// One row per key of every index, in the order of the keys. A key that is an expression has no column name, and keys
+// past indnkeyatts are the INCLUDE columns of a covering index
 const selectIndexes = `SELECT
+    t.relname AS table_name,
     i.relname AS index_name,
     a.attname AS column_name,
     ix.indisunique,
-    ix.indisprimary
+    ix.indisprimary,
+    am.amname,
+    k.ord > ix.indnkeyatts AS is_include,
+    pg_get_indexdef(ix.indexrelid, k.ord::int, true) AS key_def,
+    pg_get_expr(ix.indpred, ix.indrelid, true) AS predicate
 FROM
-    pg_class t,
-    pg_class i,
-    pg_index ix,
-    pg_attribute a,
-    pg_namespace ns
+    pg_index ix
+    JOIN pg_class t ON t.oid = ix.indrelid AND t.relkind = 'r'
+    JOIN pg_namespace ns ON ns.oid = t.relnamespace
+    JOIN pg_class i ON i.oid = ix.indexrelid
+    JOIN pg_am am ON am.oid = i.relam
+    CROSS JOIN LATERAL unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+    LEFT OUTER JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum AND k.attnum <> 0
 WHERE
-    t.oid = ix.indrelid
-    AND i.oid = ix.indexrelid
-    AND a.attrelid = t.oid
-    AND a.attnum = ANY(ix.indkey)
-    AND t.relkind = 'r'
-    AND t.relname = $2
-    AND t.relnamespace = ns.oid
-    AND ns.nspname = $1
+    ns.nspname = $1
 ORDER BY
-    i.relname`
+    t.relname,
+    i.relname,
+    k.ord`
 
+// One row per column of every foreign key, in the order of the columns. The rows of a foreign key are grouped by its
+// constraint, since a table can have more than one foreign key to the same table
 const selectForeignRelationships = `SELECT DISTINCT
+	kcu.table_name AS local_table,
+	kcu.constraint_name,
 	f_kcu.table_schema AS foreign_schema,
 	f_kcu.table_name AS foreign_table,
 	f_kcu.column_name AS foreign_column,
@@ -79,203 +161,579 @@ FROM
 	    AND f_kcu.ordinal_position = kcu.position_in_unique_constraint
 WHERE
 	kcu.table_schema = $1
-	AND kcu.table_name = $2
 	AND kcu.position_in_unique_constraint IS NOT NULL
 ORDER BY
-	foreign_schema, foreign_table`
+	local_table, kcu.constraint_name, f_kcu.ordinal_position`
 
 type Provider struct {
 	dbmap.Config
+	types *userTypes
+	// Opened by the first read and kept for the next, so that watching does not reconnect every time
+	db *sql.DB
+}
+
+// The user defined domains and composite types of the database, keyed by their schema qualified name
+type userTypes struct {
+	domains    map[string]*dbmap.Domain
+	composites map[string]*dbmap.CompositeType
+}
+
+// A domain as read from the catalog, before it has been resolved to its base type
+type domainRow struct {
+	domain       dbmap.Domain
+	baseSchema   string
+	baseName     string
+	baseDataType string
+	baseKind     string
+	isArray      bool
+}
+
+// A composite type attribute as read from the catalog, before its type has been resolved
+type attributeRow struct {
+	column        dbmap.Column
+	typeSchema    string
+	typeName      string
+	typeKind      string
+	isArray       bool
+	elementSchema string
+	elementName   string
 }
 
-func (provider *Provider) ReadDatabase() *dbmap.Database {
+// ReadDatabase Reads the tables of every schema in the configuration. The returned error is a *dbmap.ConnectError
+// when the database cannot be connected to, or a *dbmap.ReadError identifying the schema (and table) that failed
+func (provider *Provider) ReadDatabase(ctx context.Context) (*dbmap.Database, error) {
 	schemaNames := provider.Generator.Schemas
 	schemas := make([]dbmap.Schema, len(schemaNames))
 
-	db := initDB(provider)
+	db, err := provider.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
 
-	var schema dbmap.Schema
-	for i, schemaName := range schemaNames {
-		schema = dbmap.Schema{SchemaName: schemaName}
-		if err := readTables(db, provider, &schema); err != nil {
-			fmt.Printf("[FAILED] Reading schema %s - %s", schemaName, err)
-			return nil
+	types, err := readUserTypes(ctx, db, provider)
+	if err != nil {
+		return nil, &dbmap.ReadError{Op: "domains and composite types", Err: err}
+	}
+	provider.types = types
+
+	// Each schema is read by one of a bounded pool of workers, so that we never need more than the connection pool
+	workers := provider.maxConnections()
+	if workers > len(schemaNames) {
+		workers = len(schemaNames)
+	}
+
+	errs := make([]error, len(schemaNames))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				schemas[i] = dbmap.Schema{SchemaName: schemaNames[i]}
+				errs[i] = readTables(ctx, db, provider, &schemas[i])
+			}
+		}()
+	}
+	for i := range schemaNames {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	tableCount := 0
+	for i, schema := range schemas {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
-		schemas[i] = schema
+		tableCount += len(schema.Tables)
 	}
+	provider.Logf("[%s] Read %d tables in %d schemas in %s\n", provider.Database.Provider, tableCount, len(schemas),
+		time.Since(start).Round(time.Millisecond))
 
 	database := dbmap.Database{DB: db, Schemas: schemas}
-	return &database
+	return &database, nil
 }
 
-func initDB(provider *Provider) *sql.DB {
-	fmt.Printf("Connecting to %s://user=%s:%s/%s\n",
-		provider.Database.Provider, provider.Database.Username, provider.Database.Host, provider.Database.Database)
+// ReadSchemaNames Returns the name of every schema that has a table, other than the system schemas
+func (provider *Provider) ReadSchemaNames(ctx context.Context) ([]string, error) {
+	db, err := provider.open(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	dataSource := "host=" + provider.Database.Host + " port=" + provider.Database.Port +
-		" user=" + provider.Database.Username + " password=" + provider.Database.Password +
-		" dbname=" + provider.Database.Database + " sslmode=disable"
+	rows, err := db.QueryContext(ctx, selectSchemaNames)
+	if err != nil {
+		return nil, &dbmap.ReadError{Op: "schemas", Err: err}
+	}
+	defer rows.Close()
 
-	var err error
-	db, err := sql.Open("postgres", dataSource)
+	schemaNames := make([]string, 0)
+	for rows.Next() {
+		var schemaName string
+		if err := rows.Scan(&schemaName); err != nil {
+			return nil, &dbmap.ReadError{Op: "schemas", Err: err}
+		}
+		schemaNames = append(schemaNames, schemaName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &dbmap.ReadError{Op: "schemas", Err: err}
+	}
+	return schemaNames, nil
+}
+
+func (provider *Provider) maxConnections() int {
+	if provider.Database.MaxConnections > 0 {
+		return provider.Database.MaxConnections
+	}
+	return 5
+}
+
+// open Returns the connection pool, connecting on the first call
+func (provider *Provider) open(ctx context.Context) (*sql.DB, error) {
+	if provider.db == nil {
+		db, err := initDB(ctx, provider)
+		if err != nil {
+			return nil, err
+		}
+		provider.db = db
+	}
+	return provider.db, nil
+}
+
+func initDB(ctx context.Context, provider *Provider) (*sql.DB, error) {
+	provider.Logf("Connecting to %s: %s\n", provider.Database.Provider, provider.RedactedDataSource())
+
+	db, err := sql.Open("postgres", provider.DataSource())
 	if err != nil {
-		log.Panic(err)
+		return nil, &dbmap.ConnectError{DataSource: provider.RedactedDataSource(), Err: err}
 	}
 
-	db.SetMaxOpenConns(5)
-	db.SetMaxIdleConns(5)
+	db.SetMaxOpenConns(provider.maxConnections())
+	db.SetMaxIdleConns(provider.maxConnections())
 	db.SetConnMaxLifetime(time.Hour)
 
-	if err = db.Ping(); err != nil {
-		log.Panic(err)
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, &dbmap.ConnectError{DataSource: provider.RedactedDataSource(), Err: err}
 	}
 
-	return db
+	return db, nil
 }
 
-func readTables(db *sql.DB, provider *Provider, schema *dbmap.Schema) (err error) {
+// readTables Reads the tables of the schema along with their columns, indexes and foreign relationships. Rather than
+// querying each table, the columns, indexes and foreign relationships of the whole schema are each read with a single
+// query and then assigned to their tables.
+func readTables(ctx context.Context, db *sql.DB, provider *Provider, schema *dbmap.Schema) (err error) {
 	if db == nil || provider == nil || schema == nil {
 		return dbmap.InvalidArguments
 	}
-	fmt.Printf("[%s] %s\n", provider.Database.Provider, schema.SchemaName)
+	provider.Logf("[%s] %s\n", provider.Database.Provider, schema.SchemaName)
+
+	if provider.types == nil {
+		if provider.types, err = readUserTypes(ctx, db, provider); err != nil {
+			provider.Logf("[%s] FAILED reading domains and composite types\n", provider.Database.Provider)
+			return readError(schema.SchemaName, "domains and composite types", err)
+		}
+	}
 
-	rows, err := db.Query(selectTables, schema.SchemaName, pq.Array(provider.Generator.ExcludedTables))
+	rows, err := db.QueryContext(ctx, selectTables, schema.SchemaName)
 	if err != nil {
-		log.Print(err)
-		return err
+		return readError(schema.SchemaName, "tables", err)
 	}
+	defer rows.Close()
 
 	var tables []dbmap.Table
+	var skipped []string
 	for rows.Next() {
 		table := dbmap.Table{}
 		if err := rows.Scan(&table.TableSchema, &table.TableName); err != nil {
-			fmt.Printf("[%s] FAILED reading tables in schema: %s\n", provider.Database.Provider, schema.SchemaName)
-			return err
+			provider.Logf("[%s] FAILED reading tables in schema: %s\n", provider.Database.Provider, schema.SchemaName)
+			return readError(schema.SchemaName, "tables", err)
 		}
 
 		if isTableExcluded(table, provider) {
-			fmt.Printf("[%s] %s.%s (excluding)\n", provider.Database.Provider, table.TableSchema, table.TableName)
+			provider.Logf("[%s] %s.%s (excluding)\n", provider.Database.Provider, table.TableSchema, table.TableName)
+			skipped = append(skipped, table.TableName)
 		} else {
-			fmt.Printf("[%s] %s.%s\n", provider.Database.Provider, table.TableSchema, table.TableName)
+			tables = append(tables, table)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return readError(schema.SchemaName, "tables", err)
+	}
 
-			if err := readColumns(db, provider, &table); err != nil {
-				fmt.Printf("[%s] FAILED reading columns for table: %s\n", provider.Database.Provider, table.TableName)
-				return err
-			}
+	columns, skippedColumns, err := readColumns(ctx, db, provider, schema.SchemaName)
+	if err != nil {
+		provider.Logf("[%s] FAILED reading columns for schema: %s\n", provider.Database.Provider, schema.SchemaName)
+		return readError(schema.SchemaName, "columns", err)
+	}
 
-			if err := readIndexes(db, provider, &table); err != nil {
-				fmt.Printf("[%s] FAILED reading indexes for table: %s\n", provider.Database.Provider, table.TableName)
-				return err
-			}
+	indexes, err := readIndexes(ctx, db, provider, schema.SchemaName)
+	if err != nil {
+		provider.Logf("[%s] FAILED reading indexes for schema: %s\n", provider.Database.Provider, schema.SchemaName)
+		return readError(schema.SchemaName, "indexes", err)
+	}
 
-			if err := readForeignRelationships(db, provider, &table); err != nil {
-				fmt.Printf("[%s] FAILED reading foreign relationships for table: %s\n",
-					provider.Database.Provider, table.TableName)
-				return err
-			}
+	relations, err := readForeignRelationships(ctx, db, provider, schema.SchemaName)
+	if err != nil {
+		provider.Logf("[%s] FAILED reading foreign relationships for schema: %s\n",
+			provider.Database.Provider, schema.SchemaName)
+		return readError(schema.SchemaName, "foreign relationships", err)
+	}
 
-			tables = append(tables, table)
+	for i := range tables {
+		table := &tables[i]
+		provider.Logf("[%s] %s.%s\n", provider.Database.Provider, table.TableSchema, table.TableName)
+		for _, columnName := range skippedColumns[table.TableName] {
+			provider.Logf("   Excluding column: %s\n", columnName)
 		}
+
+		table.Columns = columns[table.TableName]
+		table.SkippedColumns = skippedColumns[table.TableName]
+		table.Indexes = indexes[table.TableName]
+		table.Relations = relations[table.TableName]
 	}
+
 	schema.Tables = tables
+	schema.SkippedTables = skipped
 	return nil
 }
 
-func isTableExcluded(table dbmap.Table, provider *Provider) bool {
-	for _, tableName := range provider.Generator.ExcludedTables {
-		if table.TableName == tableName {
-			return true
-		}
+// readError Identifies the schema that failed to be read, unless the error already identifies it
+func readError(schemaName string, op string, err error) error {
+	var readErr *dbmap.ReadError
+	if errors.As(err, &readErr) || err == dbmap.InvalidArguments {
+		return err
 	}
-	return false
+	return &dbmap.ReadError{Schema: schemaName, Op: op, Err: err}
 }
 
-func readColumns(db *sql.DB, provider *Provider, table *dbmap.Table) (err error) {
-	if db == nil || provider == nil || table == nil {
-		return dbmap.InvalidArguments
+func isTableExcluded(table dbmap.Table, provider *Provider) bool {
+	return provider.IsTableExcluded(table.TableSchema, table.TableName)
+}
+
+// readColumns Reads the columns of every table in the schema, keyed by table name, along with the names of the
+// columns that were excluded
+func readColumns(ctx context.Context, db *sql.DB, provider *Provider, schemaName string) (columns map[string][]dbmap.Column,
+	skipped map[string][]string, err error) {
+	if db == nil || provider == nil || provider.types == nil || schemaName == "" {
+		return nil, nil, dbmap.InvalidArguments
 	}
 
-	rows, err := db.Query(selectColumns, table.TableSchema, table.TableName)
+	rows, err := db.QueryContext(ctx, selectColumns, schemaName)
 	if err != nil {
-		log.Print(err)
-		return err
+		return nil, nil, err
 	}
+	defer rows.Close()
 
 	var columnDefault sql.NullString
-	var columns []dbmap.Column
+	var udtSchema string
+	var udtName string
+	var domainSchema sql.NullString
+	var domainName sql.NullString
+	var isArray bool
+	var elementSchema string
+	var elementName string
+	columns = make(map[string][]dbmap.Column)
+	skipped = make(map[string][]string)
 	for rows.Next() {
 		column := dbmap.Column{}
-		column.TableSchema = table.TableSchema
-		column.TableName = table.TableName
+		column.TableSchema = schemaName
+
+		if err := rows.Scan(&column.TableName, &column.ColumnName, &column.OrdinalPosition, &column.DataType,
+			&column.UdtName, &columnDefault, &column.IsNullable, &column.IsPrimaryKey, &column.IsSequence, &udtSchema,
+			&udtName, &domainSchema, &domainName, &isArray, &column.ArrayDims, &elementSchema,
+			&elementName); err != nil {
+			provider.Logf("[%s] FAILED reading columns for schema: %s\n", provider.Database.Provider, schemaName)
+			return nil, nil, err
+		}
 
-		if err := rows.Scan(&column.ColumnName, &column.OrdinalPosition, &column.DataType, &column.UdtName,
-			&columnDefault, &column.IsNullable, &column.IsPrimaryKey, &column.IsSequence); err != nil {
-			fmt.Printf("[%s] FAILED reading columns for table: %s\n", provider.Database.Provider, table.TableName)
-			return err
+		if provider.IsTableExcluded(column.TableSchema, column.TableName) {
+			continue
 		}
 
 		if isColumnExcluded(column, provider) {
-			fmt.Printf("   Excluding column: %s\n", column.ColumnName)
+			skipped[column.TableName] = append(skipped[column.TableName], column.ColumnName)
 		} else {
 			if columnDefault.Valid {
 				column.ColumnDefault = columnDefault.String
 			}
-			columns = append(columns, column)
+			if domainName.Valid {
+				applyDomain(&column, provider.types.domains[domainSchema.String+"."+domainName.String])
+			} else {
+				column.Composite = provider.types.composites[udtSchema+"."+udtName]
+			}
+			if isArray {
+				applyArray(&column, elementSchema, elementName, provider.types)
+			}
+
+			if column.ArrayDims > 1 {
+				return nil, nil, &dbmap.ReadError{Schema: schemaName, Table: column.TableName, Op: "columns",
+					Err: fmt.Errorf("%s is a %d dimensional array (%s). Only single dimension arrays can be mapped "+
+						"to a repeated field, please add the column to excluded_columns", column.ColumnName,
+						column.ArrayDims, column.UdtName)}
+			}
+			columns[column.TableName] = append(columns[column.TableName], column)
 		}
 	}
-	table.Columns = columns
-	return nil
+	return columns, skipped, rows.Err()
 }
 
-func isColumnExcluded(column dbmap.Column, provider *Provider) bool {
-	for _, excludedColumn := range provider.Generator.ExcludedColumns {
-		if excludedColumn.Tablename == column.TableSchema+"."+column.TableName {
-			for _, c := range excludedColumn.Columns {
-				if c == column.ColumnName {
-					return true
-				}
+// applyDomain Maps a column defined by a domain through to the domains base type. The domain itself is kept on the
+// column so that its name and constraints are available to the generators
+func applyDomain(column *dbmap.Column, domain *dbmap.Domain) {
+	if domain == nil {
+		return
+	}
+
+	column.Domain = domain
+	column.DataType = domain.DataType
+	column.UdtName = domain.BaseType
+	column.Composite = domain.Composite
+	if domain.IsNotNull {
+		column.IsNullable = false
+	}
+	if column.ColumnDefault == "" {
+		column.ColumnDefault = domain.Default
+	}
+}
+
+// applyArray Marks the column as an array of the element type, which is the typelem of the array type in the
+// catalog. Whether a type is an array is told by its typcategory rather than by attndims, which postgres does not
+// enforce and leaves at 0 for a column created by CREATE TABLE AS or over a domain, so the dimensions are only what
+// was declared, and at least 1. An element that is a domain or a composite type is resolved to it.
+func applyArray(column *dbmap.Column, elementSchema string, elementName string, types *userTypes) {
+	column.DataType = "ARRAY"
+	if column.ArrayDims < 1 {
+		column.ArrayDims = 1
+	}
+
+	// A column of an array type is named the way format_type names it, such as integer[], while the base type of a
+	// domain is named as it is in the catalog, such as _int4, whose element is then named int4
+	column.ElementType = elementName
+	if strings.HasSuffix(column.UdtName, "[]") {
+		column.ElementType = strings.TrimSuffix(column.UdtName, "[]")
+	}
+	elementKey := elementSchema + "." + elementName
+	if domain := types.domains[elementKey]; domain != nil {
+		column.ElementType = domain.BaseType
+		column.Composite = domain.Composite
+	} else if composite := types.composites[elementKey]; composite != nil {
+		column.Composite = composite
+	}
+}
+
+func readUserTypes(ctx context.Context, db *sql.DB, provider *Provider) (types *userTypes, err error) {
+	if db == nil || provider == nil {
+		return nil, dbmap.InvalidArguments
+	}
+
+	attributes, err := readCompositeTypes(ctx, db, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	domains, err := readDomains(ctx, db, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveUserTypes(domains, attributes), nil
+}
+
+func readDomains(ctx context.Context, db *sql.DB, provider *Provider) (domains []domainRow, err error) {
+	rows, err := db.QueryContext(ctx, selectDomains)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var typeDefault sql.NullString
+	for rows.Next() {
+		row := domainRow{}
+		if err := rows.Scan(&row.domain.DomainSchema, &row.domain.DomainName, &row.baseSchema, &row.baseName,
+			&row.baseDataType, &row.baseKind, &row.isArray, &row.domain.IsNotNull, &typeDefault,
+			pq.Array(&row.domain.Constraints)); err != nil {
+			provider.Logf("[%s] FAILED reading domains\n", provider.Database.Provider)
+			return nil, err
+		}
+
+		if typeDefault.Valid {
+			row.domain.Default = typeDefault.String
+		}
+		domains = append(domains, row)
+	}
+	return domains, rows.Err()
+}
+
+func readCompositeTypes(ctx context.Context, db *sql.DB, provider *Provider) (attributes []attributeRow, err error) {
+	rows, err := db.QueryContext(ctx, selectCompositeTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := attributeRow{column: dbmap.Column{IsNullable: true}}
+		if err := rows.Scan(&row.column.TableSchema, &row.column.TableName, &row.column.ColumnName,
+			&row.column.OrdinalPosition, &row.column.UdtName, &row.typeSchema, &row.typeName, &row.typeKind,
+			&row.isArray, &row.elementSchema, &row.elementName); err != nil {
+			provider.Logf("[%s] FAILED reading composite types\n", provider.Database.Provider)
+			return nil, err
+		}
+		attributes = append(attributes, row)
+	}
+	return attributes, rows.Err()
+}
+
+// resolveUserTypes Builds the composite types from their attributes and resolves every domain to its base type. A
+// domain over another domain inherits the base type, default, not null and constraints of the domain it is over.
+func resolveUserTypes(domains []domainRow, attributes []attributeRow) *userTypes {
+	types := userTypes{
+		domains:    make(map[string]*dbmap.Domain),
+		composites: make(map[string]*dbmap.CompositeType),
+	}
+
+	for _, attribute := range attributes {
+		key := attribute.column.TableSchema + "." + attribute.column.TableName
+		if _, ok := types.composites[key]; !ok {
+			types.composites[key] = &dbmap.CompositeType{
+				TypeSchema: attribute.column.TableSchema,
+				TypeName:   attribute.column.TableName,
 			}
 		}
 	}
-	return false
+
+	rows := make(map[string]domainRow)
+	for _, row := range domains {
+		rows[row.domain.DomainSchema+"."+row.domain.DomainName] = row
+	}
+
+	var resolve func(key string) *dbmap.Domain
+	resolve = func(key string) *dbmap.Domain {
+		if domain, ok := types.domains[key]; ok {
+			return domain
+		}
+		row, ok := rows[key]
+		if !ok {
+			return nil
+		}
+
+		domain := row.domain
+		domain.Constraints = nil
+		baseKey := row.baseSchema + "." + row.baseName
+		var base *dbmap.Domain
+		if row.baseKind == "d" {
+			base = resolve(baseKey)
+		}
+
+		if base != nil {
+			domain.DataType = base.DataType
+			domain.BaseSchema = base.BaseSchema
+			domain.BaseType = base.BaseType
+			domain.Composite = base.Composite
+			domain.IsNotNull = domain.IsNotNull || base.IsNotNull
+			if domain.Default == "" {
+				domain.Default = base.Default
+			}
+			domain.Constraints = append(domain.Constraints, base.Constraints...)
+		} else {
+			domain.BaseSchema = row.baseSchema
+			domain.BaseType = row.baseName
+			domain.DataType = dataType(row.baseSchema, row.baseDataType, row.baseKind, row.isArray)
+			if row.baseKind == "c" {
+				domain.Composite = types.composites[baseKey]
+			}
+		}
+		domain.Constraints = append(domain.Constraints, row.domain.Constraints...)
+
+		types.domains[key] = &domain
+		return &domain
+	}
+
+	for key := range rows {
+		resolve(key)
+	}
+
+	for _, attribute := range attributes {
+		column := attribute.column
+		column.DataType = dataType(attribute.typeSchema, column.UdtName, attribute.typeKind, attribute.isArray)
+		typeKey := attribute.typeSchema + "." + attribute.typeName
+		if attribute.typeKind == "d" {
+			applyDomain(&column, types.domains[typeKey])
+		} else if attribute.typeKind == "c" {
+			column.Composite = types.composites[typeKey]
+		}
+		if attribute.isArray {
+			applyArray(&column, attribute.elementSchema, attribute.elementName, &types)
+		}
+
+		composite := types.composites[column.TableSchema+"."+column.TableName]
+		composite.Attributes = append(composite.Attributes, column)
+	}
+
+	return &types
 }
 
-func readIndexes(db *sql.DB, provider *Provider, table *dbmap.Table) (err error) {
-	if db == nil || provider == nil || table == nil {
-		return dbmap.InvalidArguments
+// dataType Returns the data type of a catalog type the same way information_schema.columns reports it
+func dataType(typeSchema string, typeName string, typeKind string, isArray bool) string {
+	if isArray {
+		return "ARRAY"
+	} else if typeKind == "c" || typeKind == "e" || typeSchema != "pg_catalog" {
+		return "USER-DEFINED"
 	}
+	return typeName
+}
+
+func isColumnExcluded(column dbmap.Column, provider *Provider) bool {
+	return provider.IsColumnExcluded(column.TableSchema, column.TableName, column.ColumnName)
+}
 
-	rows, err := db.Query(selectIndexes, table.TableSchema, table.TableName)
+// readIndexes Reads the indexes of every table in the schema, keyed by table name
+func readIndexes(ctx context.Context, db *sql.DB, provider *Provider, schemaName string) (indexes map[string][]dbmap.Index, err error) {
+	if db == nil || provider == nil || schemaName == "" {
+		return nil, dbmap.InvalidArguments
+	}
+
+	rows, err := db.QueryContext(ctx, selectIndexes, schemaName)
 	if err != nil {
-		log.Print(err)
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	var index dbmap.Index
-	var indexes []dbmap.Index
+	indexes = make(map[string][]dbmap.Index)
+	var index *dbmap.Index
+	var tableName string
 	var indexName string
 	var isUnique bool
 	var isPrimaryKey bool
-	var workingIndex string
-	var columns []string
-	var columnName string
-	var firstTime = true
+	var columnName sql.NullString
+	var method string
+	var isInclude bool
+	var keyDef string
+	var predicate sql.NullString
 	for rows.Next() {
-		if err := rows.Scan(&indexName, &columnName, &isUnique, &isPrimaryKey); err != nil {
-			fmt.Printf("[%s] FAILED reading indexes for table: %s\n", provider.Database.Provider, table.TableName)
-			return err
+		if err := rows.Scan(&tableName, &indexName, &columnName, &isUnique, &isPrimaryKey, &method, &isInclude,
+			&keyDef, &predicate); err != nil {
+			provider.Logf("[%s] FAILED reading indexes for schema: %s\n", provider.Database.Provider, schemaName)
+			return nil, err
 		}
 
-		// New index, so add the working and start a new one
-		if indexName != workingIndex {
-			index.Columns = columns
-			if firstTime {
-				firstTime = false
-			} else {
-				indexes = append(indexes, index)
-			}
+		// New index, so start a new one
+		if index == nil || index.TableName != tableName || index.IndexName != indexName {
+			tableIndexes := append(indexes[tableName], dbmap.Index{
+				TableSchema: schemaName,
+				TableName:   tableName,
+				IndexName:   indexName,
+				Columns:     make([]string, 0),
+				Method:      method,
+				Predicate:   predicate.String,
+			})
+			indexes[tableName] = tableIndexes
+			index = &tableIndexes[len(tableIndexes)-1]
 
-			index = dbmap.Index{TableSchema: table.TableSchema, TableName: table.TableName, IndexName: indexName}
 			if isPrimaryKey {
 				index.IndexType = dbmap.PrimaryKey
 			} else if isUnique {
@@ -283,77 +741,68 @@ func readIndexes(db *sql.DB, provider *Provider, table *dbmap.Table) (err error)
 			} else {
 				index.IndexType = dbmap.NonUnique
 			}
+		}
 
-			columns = make([]string, 0)
+		if isInclude {
+			index.Include = append(index.Include, columnName.String)
+		} else if columnName.Valid {
+			index.Columns = append(index.Columns, columnName.String)
+		} else {
+			index.Columns = append(index.Columns, keyDef)
+			index.Expressions = append(index.Expressions, keyDef)
 		}
-		workingIndex = indexName
-		columns = append(columns, columnName)
-	}
-	if !firstTime {
-		index.Columns = columns
-		indexes = append(indexes, index)
 	}
-	table.Indexes = indexes
-	return nil
+	return indexes, rows.Err()
 }
 
-func readForeignRelationships(db *sql.DB, provider *Provider, table *dbmap.Table) (err error) {
-	if db == nil || provider == nil || table == nil {
-		return dbmap.InvalidArguments
+// readForeignRelationships Reads the foreign relationships of every table in the schema, keyed by table name
+func readForeignRelationships(ctx context.Context, db *sql.DB, provider *Provider, schemaName string) (
+	relations map[string][]dbmap.ForeignRelation, err error) {
+	if db == nil || provider == nil || schemaName == "" {
+		return nil, dbmap.InvalidArguments
 	}
 
-	rows, err := db.Query(selectForeignRelationships, table.TableSchema, table.TableName)
+	rows, err := db.QueryContext(ctx, selectForeignRelationships, schemaName)
 	if err != nil {
-		log.Print(err)
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	var relation dbmap.ForeignRelation
-	var relations []dbmap.ForeignRelation
+	relations = make(map[string][]dbmap.ForeignRelation)
+	var relation *dbmap.ForeignRelation
+	var lTable string
+	var workingTable string
+	var constraintName string
 	var fSchema string
 	var fTable string
 	var fColumn string
 	var lColumn string
-	var columns []dbmap.ForeignColumns
 	var oPos int32
-	var workingTable string
-	var workingSchema string
-	var firstTime = true
 	for rows.Next() {
-		if err := rows.Scan(&fSchema, &fTable, &fColumn, &lColumn, &oPos); err != nil {
-			fmt.Printf("[%s] FAILED reading indexes for table: %s\n", provider.Database.Provider, table.TableName)
-			return err
+		if err := rows.Scan(&lTable, &constraintName, &fSchema, &fTable, &fColumn, &lColumn, &oPos); err != nil {
+			provider.Logf("[%s] FAILED reading foreign relationships for schema: %s\n", provider.Database.Provider,
+				schemaName)
+			return nil, err
 		}
 
-		if fTable != workingTable || fSchema != workingSchema {
-			relation.Columns = columns
-			if firstTime {
-				firstTime = false
-			} else {
-				relations = append(relations, relation)
-			}
-
-			relation = dbmap.ForeignRelation{
-				ForeignSchema: fSchema,
-				ForeignTable:  fTable,
-				Columns:       nil,
-				RelationType:  dbmap.ZeroOneOrMore,
-			}
-
-			columns = make([]dbmap.ForeignColumns, 0)
+		// New foreign key, so start a new relation
+		if relation == nil || lTable != workingTable || constraintName != relation.ConstraintName {
+			tableRelations := append(relations[lTable], dbmap.ForeignRelation{
+				ConstraintName: constraintName,
+				ForeignSchema:  fSchema,
+				ForeignTable:   fTable,
+				Columns:        make([]dbmap.ForeignColumns, 0),
+				RelationType:   dbmap.ZeroOneOrMore,
+			})
+			relations[lTable] = tableRelations
+			relation = &tableRelations[len(tableRelations)-1]
 		}
-		workingTable = fTable
-		workingSchema = fSchema
-		columns = append(columns, dbmap.ForeignColumns{
+		workingTable = lTable
+		relation.Columns = append(relation.Columns, dbmap.ForeignColumns{
 			ForeignColumn:   fColumn,
 			LocalColumn:     lColumn,
 			OrdinalPosition: oPos,
 		})
 	}
-	if !firstTime {
-		relation.Columns = columns
-		relations = append(relations, relation)
-	}
-	table.Relations = relations
-	return nil
+	return relations, rows.Err()
 }
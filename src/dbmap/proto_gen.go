@@ -3,26 +3,33 @@ package dbmap
 import (
 	"fmt"
 	"github.com/iancoleman/strcase"
-	"os"
+	"io"
 	"path/filepath"
 	"strconv"
 	"strings"
 )
 
+// GenerateProto Writes a proto for every table under proto.path
 func GenerateProto(cfg Config, database *Database) error {
+	return GenerateProtoTo(DiskFileSystem{}, cfg, database)
+}
+
+// GenerateProtoTo Writes a proto for every table to the file system, which can be in memory. The returned error is a
+// *GenerateError identifying the table that failed
+func GenerateProtoTo(fs FileSystem, cfg Config, database *Database) error {
 	for _, schema := range database.Schemas {
-		dir, _ := os.Getwd()
-		path := filepath.Join(dir, cfg.Proto.Path, schema.SchemaName)
-		if err := os.MkdirAll(path, os.ModePerm); err != nil {
-			fmt.Printf("FAILED to create output path with permission 0755 - %s : %s\n", path, err)
-			return err
+		path := filepath.Join(cfg.Proto.Path, schema.SchemaName)
+		if err := fs.MkdirAll(path); err != nil {
+			cfg.Logf("FAILED to create output path with permission 0755 - %s : %s\n", path, err)
+			return &GenerateError{Schema: schema.SchemaName, Path: path, Err: err}
 		}
 
 		for _, table := range schema.Tables {
-			fmt.Printf("%s/%s.proto\n", table.TableSchema, table.TableName)
-			if err := writeProto(cfg, table); err != nil {
-				fmt.Printf("Failed to write proto for table %s in path %s : %s\n", table.TableName, path, err)
-				return err
+			cfg.Logf("%s/%s.proto\n", table.TableSchema, table.TableName)
+			if err := writeProto(fs, cfg, table); err != nil {
+				cfg.Logf("Failed to write proto for table %s in path %s : %s\n", table.TableName, path, err)
+				return &GenerateError{Schema: table.TableSchema, Table: table.TableName,
+					Path: ProtoFilename(cfg, table.TableSchema, table.TableName), Err: err}
 			}
 		}
 	}
@@ -30,14 +37,13 @@ func GenerateProto(cfg Config, database *Database) error {
 	return nil
 }
 
-func writeProto(cfg Config, table Table) error {
-	filename := filepath.Join(cfg.Proto.Path, table.TableSchema, table.TableName+".proto")
-	f, err := os.Create(filename)
+func writeProto(fs FileSystem, cfg Config, table Table) error {
+	filename := ProtoFilename(cfg, table.TableSchema, table.TableName)
+	f, err := fs.Create(filename)
 	if err != nil {
-		fmt.Printf("Failed to create file %s : %s\n", filename, err)
+		cfg.Logf("Failed to create file %s : %s\n", filename, err)
 		return err
 	}
-	defer f.Close()
 
 	_, _ = fmt.Fprintf(f, "//-------------------------------------------------------------------\n")
 	_, _ = fmt.Fprintf(f, "// This file is automatically generated from the database schema.\n")
@@ -48,7 +54,8 @@ func writeProto(cfg Config, table Table) error {
 	_, _ = fmt.Fprintf(f, "package %s;\n\n", table.TableSchema)
 	_, _ = fmt.Fprintf(f, "option cc_enable_arenas = true;\n")
 	_, _ = fmt.Fprintf(f, "option java_package = \"%s.%s\";\n", cfg.Proto.JavaPackage, table.TableSchema)
-	_, _ = fmt.Fprintf(f, "option java_outer_classname = \"%sProto\";\n", table.TableName)
+	_, _ = fmt.Fprintf(f, "option java_outer_classname = \"%sProto\";\n", cfg.MessageName(table.TableSchema,
+		table.TableName))
 	_, _ = fmt.Fprintf(f, "option objc_class_prefix = \"%s\";\n\n", cfg.Proto.ObjCPrefix)
 
 	if cfg.EmbedRelationships {
@@ -57,19 +64,30 @@ func writeProto(cfg Config, table Table) error {
 
 	maybeWriteOtherImports(f, table)
 
-	_, _ = fmt.Fprintf(f, "message %s {\n", strcase.ToCamel(table.TableName))
+	_, _ = fmt.Fprintf(f, "message %s {\n", cfg.MessageName(table.TableSchema, table.TableName))
 
+	writeNestedMessages(f, cfg, table)
 	writeFields(f, cfg, table)
 
 	_, _ = fmt.Fprint(f, "}\n")
 
-	return nil
+	return f.Close()
 }
 
-func maybeWriteOtherImports(f *os.File, table Table) {
+// ProtoFilename Returns the path of the proto generated for the table
+func ProtoFilename(cfg Config, schemaName string, tableName string) string {
+	return filepath.Join(cfg.Proto.Path, schemaName, tableName+".proto")
+}
+
+func maybeWriteOtherImports(f io.Writer, table Table) {
+	columns := append([]Column{}, table.Columns...)
+	for _, composite := range collectComposites(table) {
+		columns = append(columns, composite.Attributes...)
+	}
+
 	tsFlag := false
 	commentFlag := false
-	for _, column := range table.Columns {
+	for _, column := range columns {
 		if strings.HasPrefix(column.UdtName, "timestamp") && ! tsFlag {
 			tsFlag = true
 			if ! commentFlag {
@@ -86,49 +104,164 @@ func maybeWriteOtherImports(f *os.File, table Table) {
 	}
 }
 
-func writeImports(f *os.File, table Table) {
+func writeImports(f io.Writer, table Table) {
 	if len(table.Relations) == 0 {
 		return
 	}
 	_, _ = fmt.Fprintf(f, "// Foreign Key Imports\n\n")
 
+	// A table with more than one foreign key to the same table imports it once
+	imported := make(map[string]bool)
 	for _, relation := range table.Relations {
-		_, _ = fmt.Fprintf(f, "import \"%s/%s.proto\";\n", relation.ForeignSchema, relation.ForeignTable)
+		proto := relation.ForeignSchema + "/" + relation.ForeignTable + ".proto"
+		if !imported[proto] {
+			imported[proto] = true
+			_, _ = fmt.Fprintf(f, "import \"%s\";\n", proto)
+		}
+	}
+	_, _ = fmt.Fprint(f, "\n")
+}
+
+// collectComposites Returns every composite type used by the columns of the table, including composite types nested
+// within them. A nested type is always ordered before the type that uses it
+func collectComposites(table Table) []*CompositeType {
+	composites := make([]*CompositeType, 0)
+	seen := make(map[*CompositeType]bool)
+
+	var collect func(composite *CompositeType)
+	collect = func(composite *CompositeType) {
+		if composite == nil || seen[composite] {
+			return
+		}
+		seen[composite] = true
+		for _, attribute := range composite.Attributes {
+			collect(attribute.Composite)
+		}
+		composites = append(composites, composite)
+	}
+
+	for _, column := range table.Columns {
+		collect(column.Composite)
+	}
+	return composites
+}
+
+// writeNestedMessages Composite types are written as messages nested within the message of the table using them
+func writeNestedMessages(f io.Writer, cfg Config, table Table) {
+	for _, composite := range collectComposites(table) {
+		_, _ = fmt.Fprintf(f, "    message %s {\n", strcase.ToCamel(composite.TypeName))
+		for i, attribute := range composite.Attributes {
+			writeField(f, cfg, "        ", attribute, i+1)
+		}
+		_, _ = fmt.Fprint(f, "    }\n\n")
+	}
+}
+
+func writeField(f io.Writer, cfg Config, indent string, column Column, number int) {
+	_, _ = fmt.Fprint(f, indent)
+	if column.DataType == "ARRAY" {
+		_, _ = fmt.Fprintf(f, "repeated ")
+	} else if cfg.Proto.Version == "proto2" {
+		_, _ = fmt.Fprintf(f, "optional ")
+	}
+	override := cfg.ColumnOverride(column.TableSchema, column.TableName, column.ColumnName)
+	fieldType, ok := mapProtoType(column)
+	if override.ProtoType != "" {
+		fieldType = override.ProtoType
+	} else if !ok {
+		cfg.Logf("[warning] Failed to map postgres datatype of %s.%s.%s to protobuf: %s. Using \"bytes\"\n",
+			column.TableSchema, column.TableName, column.ColumnName, column.UdtName)
+	}
+
+	_, _ = fmt.Fprintf(f, "%s %s = %d", fieldType, cfg.FieldName(column), number)
+	if override.JSON != "" {
+		_, _ = fmt.Fprintf(f, " [json_name = \"%s\"]", override.JSON)
+	}
+	_, _ = fmt.Fprint(f, ";")
+	if column.Domain != nil {
+		_, _ = fmt.Fprintf(f, " // domain %s.%s", column.Domain.DomainSchema, column.Domain.DomainName)
 	}
 	_, _ = fmt.Fprint(f, "\n")
 }
 
-func writeFields(f *os.File, cfg Config, table Table) {
+// protoType Returns the protobuf type of the column, which is the nested message for a composite type or the type of
+// the elements of an array
+func protoType(column Column) string {
+	fieldType, _ := mapProtoType(column)
+	return fieldType
+}
+
+// mapProtoType Returns the protobuf type of the column and whether its datatype is known, since one that is not is
+// written as bytes
+func mapProtoType(column Column) (string, bool) {
+	if column.Composite != nil {
+		return strcase.ToCamel(column.Composite.TypeName), true
+	} else if column.DataType == "ARRAY" {
+		return sqlToProtoType(column.ElementType)
+	}
+	return sqlToProtoType(column.UdtName)
+}
+
+func writeFields(f io.Writer, cfg Config, table Table) {
 	// If we are writing the protos with embedded messages, we need to build a column map that will handle the use
 	// cases: 1) two tables with the same name from different schemas, and 2) two of the same tables with different
 	// referencing column names
 	if cfg.EmbedRelationships {
 		fields := buildFieldList(table)
 		counter := 0
-		for column, rel := range fields {
+		for _, column := range table.Columns {
+			rel, ok := fields[column]
+			if !ok {
+				continue
+			}
 			counter += 1
 			if rel != nil {
 				_, _ = fmt.Fprintf(f, "    optional %s.%s %s = %d; // => %s\n", rel.ForeignSchema,
-					strcase.ToCamel(rel.ForeignTable), rel.MapName, counter, getLocalKeys(rel))
+					cfg.MessageName(rel.ForeignSchema, rel.ForeignTable), rel.MapName, counter, getLocalKeys(rel))
 			} else {
-				if column.DataType == "ARRAY" {
-					_, _ = fmt.Fprintf(f, "    repeated ")
-				} else if cfg.Proto.Version == "proto2" {
-					_, _ = fmt.Fprintf(f, "    optional ")
-				}
-				_, _ = fmt.Fprintf(f, "%s %s = %d;\n", sqlToProto(column.UdtName), column.ColumnName, counter)
+				writeField(f, cfg, "    ", column, counter)
 			}
 		}
 	} else {
 		for i, column := range table.Columns {
-			if column.DataType == "ARRAY" {
-				_, _ = fmt.Fprintf(f, "    repeated ")
-			} else if cfg.Proto.Version == "proto2" {
-				_, _ = fmt.Fprintf(f, "    optional ")
+			writeField(f, cfg, "    ", column, i+1)
+		}
+	}
+
+	// The columns of the select transforms that are not columns of the table follow them, such as a lat and lon read
+	// from a geography
+	number := len(table.Columns)
+	if cfg.EmbedRelationships {
+		number = len(buildFieldList(table))
+	}
+	for _, column := range selectColumns(cfg, table) {
+		number++
+		writeField(f, cfg, "    ", column, number)
+	}
+}
+
+// selectColumns Returns the columns that the select transforms of the table add to it, with the datatype of the
+// transform
+func selectColumns(cfg Config, table Table) []Column {
+	existing := make(map[string]bool)
+	for _, column := range table.Columns {
+		existing[column.ColumnName] = true
+	}
+
+	columns := make([]Column, 0)
+	for _, transform := range cfg.Generator.Transforms {
+		if !MatchTable(transform.Tablename, table.TableSchema, table.TableName) {
+			continue
+		}
+		for _, xform := range transform.Xforms.Select {
+			if !existing[xform.Columnname] {
+				existing[xform.Columnname] = true
+				columns = append(columns, Column{TableSchema: table.TableSchema, TableName: table.TableName,
+					ColumnName: xform.Columnname, DataType: xform.Datatype, UdtName: xform.Datatype, IsNullable: true})
 			}
-			_, _ = fmt.Fprintf(f, "%s %s = %d;\n", sqlToProto(column.UdtName), column.ColumnName, i + 1)
 		}
 	}
+	return columns
 }
 
 func getLocalKeys(relation *ForeignRelation) string {
@@ -168,7 +301,7 @@ func buildFieldList(table Table) map[Column]*ForeignRelation {
 }
 
 func removeCompositeColumns(fcolumns []ForeignColumns, columns *[]Column){
-	set := make(map[string]Column)
+	kept := make([]Column, 0)
 	firstFlag := true
 	for _, col := range *columns {
 		keep := true
@@ -182,14 +315,11 @@ func removeCompositeColumns(fcolumns []ForeignColumns, columns *[]Column){
 		}
 
 		if keep {
-			set[col.ColumnName] = col
+			kept = append(kept, col)
 		}
 	}
 
-	*columns = make([]Column, 0)
-	for _, col := range set {
-		*columns = append(*columns, col)
-	}
+	*columns = kept
 }
 
 func getRelation(column Column, relations []ForeignRelation) *ForeignRelation {
@@ -203,34 +333,38 @@ func getRelation(column Column, relations []ForeignRelation) *ForeignRelation {
 	return nil
 }
 
-func sqlToProto(sType string) string {
-	if sType == "bigint" || sType == "bigint[]" || sType == "bigserial" || sType == "serial8" {
-		return "int64"
+// sqlToProtoType Maps a postgres datatype to its protobuf scalar type, returning false when the type is not known
+func sqlToProtoType(sType string) (string, bool) {
+	if sType == "bigint" || sType == "int8" || sType == "bigserial" || sType == "serial8" {
+		return "int64", true
+	} else if strings.HasSuffix(sType, "range") || sType == "interval" {
+		return "string", true
 	} else if strings.HasPrefix(sType, "int") || strings.HasPrefix(sType, "bit") ||
-		strings.HasPrefix(sType, "smallint") || sType == "int2" || sType == "smallserial" || sType == "serial" {
-		return "int32"
+		strings.HasPrefix(sType, "smallint") || sType == "smallserial" || sType == "serial" || sType == "oid" {
+		return "int32", true
 	} else if strings.HasPrefix(sType, "bool") {
-		return "bool"
+		return "bool", true
 	} else if sType == "jsonb" {
-		return "bytes"
+		return "bytes", true
 	} else if strings.HasPrefix(sType, "json") {
-		return "string"
-	} else if strings.HasPrefix(sType, "char") || strings.HasPrefix(sType, "varchar") ||
-		strings.HasPrefix(sType, "text") || sType == "xml" || sType == "uuid" {
-		return "string"
+		return "string", true
+	} else if strings.HasPrefix(sType, "char") || strings.HasPrefix(sType, "varchar") || sType == "bpchar" ||
+		strings.HasPrefix(sType, "text") || sType == "xml" || sType == "uuid" || sType == "citext" ||
+		sType == "name" || sType == "inet" || sType == "cidr" || strings.HasPrefix(sType, "macaddr") ||
+		sType == "tsvector" {
+		return "string", true
 	} else if sType == "money" || strings.HasPrefix(sType, "number") || sType == "numeric" ||
 		strings.HasPrefix(sType, "decimal") || sType == "float8" || sType == "double precision" {
-		return "double"
-	} else if sType == "float" || sType == "real" {
-		return "float64"
+		return "double", true
+	} else if sType == "float" || sType == "float4" || sType == "real" {
+		return "float", true
+	} else if strings.HasPrefix(sType, "timestamp") {
+		return "google.protobuf.Timestamp", true
 	} else if strings.HasPrefix(sType, "time") || sType == "date" {
-		return "int64"
+		return "int64", true
 	} else if sType == "bytea" {
-		return "bytes"
-	} else if strings.HasPrefix(sType, "timestamp") {
-		return "google.protobuf.Timestamp"
+		return "bytes", true
 	} else {
-		fmt.Printf("[warning] Failed to map postgres datatype to protobuf: %s. Using \"bytes\"\n", sType)
-		return "bytes"
+		return "bytes", false
 	}
 }
@@ -1,6 +1,7 @@
 package dbmap
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -77,3 +78,164 @@ func TestRemoveCompositeColumns(t *testing.T) {
 		t.Fatal("Expected only 3 columns")
 	}
 }
+
+func TestCollectComposites(t *testing.T) {
+	point := &CompositeType{TypeSchema: "public", TypeName: "point_t", Attributes: []Column{
+		{ColumnName: "x", UdtName: "double precision"},
+		{ColumnName: "y", UdtName: "double precision"},
+	}}
+	address := &CompositeType{TypeSchema: "public", TypeName: "address_t", Attributes: []Column{
+		{ColumnName: "street", UdtName: "character varying"},
+		{ColumnName: "location", UdtName: "public.point_t", Composite: point},
+	}}
+
+	table := Table{TableName: "site", TableSchema: "public", Columns: []Column{
+		{ColumnName: "site_id", UdtName: "integer"},
+		{ColumnName: "home", UdtName: "public.address_t", Composite: address},
+		{ColumnName: "work", UdtName: "public.address_t", Composite: address},
+		{ColumnName: "center", UdtName: "public.point_t", Composite: point},
+	}}
+
+	composites := collectComposites(table)
+	if len(composites) != 2 {
+		t.Fatal("Expected 2 composite types")
+	}
+
+	if composites[0] != point || composites[1] != address {
+		t.Fatal("Expected the nested point_t before address_t")
+	}
+
+	if protoType(table.Columns[1]) != "AddressT" {
+		t.Fatal("Expected a composite column to use the nested message")
+	}
+}
+
+func TestProtoTypeArrays(t *testing.T) {
+	address := &CompositeType{TypeSchema: "public", TypeName: "address_t"}
+	cases := []struct {
+		column   Column
+		expected string
+	}{
+		{Column{DataType: "ARRAY", UdtName: "integer[]", ElementType: "integer"}, "int32"},
+		{Column{DataType: "ARRAY", UdtName: "bigint[]", ElementType: "bigint"}, "int64"},
+		{Column{DataType: "ARRAY", UdtName: "text[]", ElementType: "text"}, "string"},
+		{Column{DataType: "ARRAY", UdtName: "uuid[]", ElementType: "uuid"}, "string"},
+		{Column{DataType: "ARRAY", UdtName: "boolean[]", ElementType: "boolean"}, "bool"},
+		{Column{DataType: "ARRAY", UdtName: "real[]", ElementType: "real"}, "float"},
+		{Column{DataType: "ARRAY", UdtName: "json[]", ElementType: "json"}, "string"},
+		{Column{DataType: "ARRAY", UdtName: "timestamp with time zone[]", ElementType: "timestamp with time zone"},
+			"google.protobuf.Timestamp"},
+		{Column{DataType: "ARRAY", UdtName: "address_t[]", ElementType: "address_t", Composite: address}, "AddressT"},
+		{Column{DataType: "timestamp without time zone", UdtName: "timestamp without time zone"},
+			"google.protobuf.Timestamp"},
+		{Column{DataType: "time without time zone", UdtName: "time without time zone"}, "int64"},
+		{Column{DataType: "ARRAY", UdtName: "_bpchar", ElementType: "bpchar"}, "string"},
+		{Column{DataType: "character", UdtName: "bpchar"}, "string"},
+	}
+
+	for i, c := range cases {
+		if protoType(c.column) != c.expected {
+			t.Errorf("%d) Expected %s for %s but got %s", i, c.expected, c.column.UdtName, protoType(c.column))
+		}
+	}
+}
+
+func TestWriteFieldsEmbeddedOrder(t *testing.T) {
+	cfg := Config{EmbedRelationships: true}
+	table := Table{TableSchema: "test_schema", TableName: "order", Columns: []Column{
+		{ColumnName: "order_id", UdtName: "int8"},
+		{ColumnName: "user_id", UdtName: "int8"},
+		{ColumnName: "created_at", UdtName: "timestamptz"},
+		{ColumnName: "note", UdtName: "text"},
+	}, Relations: []ForeignRelation{{ForeignSchema: "test_schema", ForeignTable: "user",
+		Columns: []ForeignColumns{{LocalColumn: "user_id", ForeignColumn: "user_id", OrdinalPosition: 1}}}}}
+
+	expected := "    int64 order_id = 1;\n" +
+		"    optional test_schema.User user = 2; // => user_id\n" +
+		"    google.protobuf.Timestamp created_at = 3;\n" +
+		"    string note = 4;\n"
+	for i := 0; i < 10; i++ {
+		var b strings.Builder
+		writeFields(&b, cfg, table)
+		if b.String() != expected {
+			t.Fatalf("Expected\n%s\nbut got\n%s", expected, b.String())
+		}
+	}
+}
+
+func TestWriteSelectTransformFields(t *testing.T) {
+	var cfg Config
+	if err := ReadFile(&cfg, writeConfig(t, validConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	table := Table{TableSchema: "test_schema", TableName: "user", Columns: []Column{
+		{TableSchema: "test_schema", TableName: "user", ColumnName: "user_id", UdtName: "integer"},
+		{TableSchema: "test_schema", TableName: "user", ColumnName: "email", UdtName: "character varying"},
+	}}
+	var b strings.Builder
+	writeFields(&b, cfg, table)
+	expected := "    int32 user_id = 1;\n" +
+		"    string email = 2;\n" +
+		"    double lat = 3;\n"
+	if b.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, b.String())
+	}
+}
+
+func TestWriteTwoForeignKeysToOneTable(t *testing.T) {
+	cfg := Config{EmbedRelationships: true}
+	table := Table{TableSchema: "test_schema", TableName: "order", Columns: []Column{
+		{ColumnName: "order_id", UdtName: "int8"},
+		{ColumnName: "buyer_id", UdtName: "int4"},
+		{ColumnName: "seller_id", UdtName: "int4"},
+	}, Relations: []ForeignRelation{
+		{ConstraintName: "fk_order_buyer", ForeignSchema: "test_schema", ForeignTable: "user",
+			Columns: []ForeignColumns{{LocalColumn: "buyer_id", ForeignColumn: "user_id", OrdinalPosition: 1}}},
+		{ConstraintName: "fk_order_seller", ForeignSchema: "test_schema", ForeignTable: "user",
+			Columns: []ForeignColumns{{LocalColumn: "seller_id", ForeignColumn: "user_id", OrdinalPosition: 1}}},
+	}}
+
+	var b strings.Builder
+	writeImports(&b, table)
+	expected := "// Foreign Key Imports\n\nimport \"test_schema/user.proto\";\n\n"
+	if b.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	writeFields(&b, cfg, table)
+	expected = "    int64 order_id = 1;\n" +
+		"    optional test_schema.User user = 2; // => buyer_id\n" +
+		"    optional test_schema.User user2 = 3; // => seller_id\n"
+	if b.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, b.String())
+	}
+}
+
+func TestGenerateProtoLog(t *testing.T) {
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{{TableSchema: "test_schema",
+		TableName: "user", Columns: []Column{
+			{TableSchema: "test_schema", TableName: "user", ColumnName: "user_id", UdtName: "int4"},
+			{TableSchema: "test_schema", TableName: "user", ColumnName: "shape", UdtName: "box"},
+		}}}}}}
+
+	var log strings.Builder
+	cfg := Config{Log: &log}
+	cfg.Proto.Path = "proto"
+	cfg.Proto.Version = "proto3"
+	if err := GenerateProtoTo(NewMemoryFileSystem(), cfg, database); err != nil {
+		t.Fatal(err)
+	}
+	expected := "test_schema/user.proto\n" +
+		"[warning] Failed to map postgres datatype of test_schema.user.shape to protobuf: box. Using \"bytes\"\n"
+	if log.String() != expected {
+		t.Errorf("Expected the progress\n%s\nbut got\n%s", expected, log.String())
+	}
+
+	// Without a Log nothing is written, which would otherwise be the stdout of the program using the package
+	cfg.Log = nil
+	if err := GenerateProtoTo(NewMemoryFileSystem(), cfg, database); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,252 @@
+package dbmap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SensitiveColumns The patterns of the column names that Scaffold excludes, since they usually hold values that should
+// not be exposed through the generated types
+var SensitiveColumns = []string{"*password*", "*hash*", "*secret*", "*token*"}
+
+// SchemaLister Implemented by providers that can list the schemas of the database, which is needed to scaffold a config
+type SchemaLister interface {
+	ReadSchemaNames(ctx context.Context) ([]string, error)
+}
+
+// Scaffold Writes a starter config for the database, which was read with every schema in cfg.Generator.Schemas and
+// nothing excluded. The columns that look sensitive are excluded and every geography or geometry column is excluded
+// and replaced by lat and lon transforms, the same as the example config. Everything else is left as a commented
+// example to be reviewed
+func Scaffold(w io.Writer, cfg Config, database *Database) {
+	_, _ = fmt.Fprintln(w, "# Config file written by go_dbmap init. Please review it, in particular the excluded")
+	_, _ = fmt.Fprintln(w, "# columns and the transforms, and then run go_dbmap generate. See config/go_dbmap.yml in")
+	_, _ = fmt.Fprintln(w, "# the go_dbmap repository for every option.")
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "database:")
+	_, _ = fmt.Fprintf(w, "  provider: %s\n", strconv.Quote(cfg.Database.Provider))
+	dsn, hasPassword := dataSourceTemplate(cfg.DataSource())
+	if hasPassword {
+		_, _ = fmt.Fprintln(w, "  # The password is read from the DBMAP_PASSWORD environment variable")
+	}
+	_, _ = fmt.Fprintf(w, "  dsn: %s\n", strconv.Quote(dsn))
+	_, _ = fmt.Fprintln(w, "  max_connections: 5")
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "output:")
+	_, _ = fmt.Fprintln(w, "  path: \"output\"")
+	_, _ = fmt.Fprintln(w, "  suffix: \"_db\"")
+	_, _ = fmt.Fprintln(w, "  lang: \"go\"")
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "# Embed foreign relationships.")
+	_, _ = fmt.Fprintln(w, "# NOTE: when using this feature, your relationships MUST BE acyclic")
+	_, _ = fmt.Fprintln(w, "embed_relationships: true")
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "# Use proto2 when NULL is a valid value of a column, so that a field can be absent")
+	_, _ = fmt.Fprintln(w, "proto:")
+	_, _ = fmt.Fprintln(w, "  path: \"output/proto\"")
+	_, _ = fmt.Fprintln(w, "  java_package: \"com.example\"")
+	_, _ = fmt.Fprintln(w, "  objc_prefix: \"DBMAP\"")
+	_, _ = fmt.Fprintln(w, "  version: \"proto2\"")
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "generator:")
+	_, _ = fmt.Fprintln(w, "  # Every schema of the database that has tables")
+	_, _ = fmt.Fprintf(w, "  schemas: %s\n", yamlList(cfg.Generator.Schemas))
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "  # Tables to skip, as \"table\" in every schema or \"schema.table\", either may be a glob")
+	if len(findTables(database, "spatial_ref_sys")) > 0 {
+		_, _ = fmt.Fprintln(w, "  excluded_tables: [\"spatial_ref_sys\"]")
+	} else {
+		_, _ = fmt.Fprintln(w, "  # excluded_tables: [\"audit.*_log\"]")
+	}
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "  # Generate a lookup for every index whose name is prefixed with 'lookup_'")
+	_, _ = fmt.Fprintln(w, "  indexed_lookups: true")
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "  # Only filter and sort the generated Find functions by the columns that lead an index")
+	_, _ = fmt.Fprintln(w, "  strict_filters: false")
+	_, _ = fmt.Fprintln(w)
+
+	writeScaffoldExclusions(w, database)
+	writeScaffoldTransforms(w, database)
+
+	_, _ = fmt.Fprintln(w, "  # Custom queries are mapped to functions. Each bind parameter is written as $name:type")
+	_, _ = fmt.Fprintln(w, "  #")
+	_, _ = fmt.Fprintln(w, "  # mapping:")
+	_, _ = fmt.Fprintln(w, "  #   -")
+	_, _ = fmt.Fprintln(w, "  #     table: \"public.account\"")
+	_, _ = fmt.Fprintln(w, "  #     queries:")
+	_, _ = fmt.Fprintln(w, "  #       -")
+	_, _ = fmt.Fprintln(w, "  #         name: \"disable_account\"")
+	_, _ = fmt.Fprintln(w, "  #         query: \"UPDATE public.account SET enabled = false WHERE email = $email:string\"")
+}
+
+// writeScaffoldExclusions Excludes the sensitive columns along with the geography and geometry columns, which are read
+// and written through the lat and lon transforms
+func writeScaffoldExclusions(w io.Writer, database *Database) {
+	_, _ = fmt.Fprintln(w, "  # Columns left out of the generated SELECT, INSERT and UPDATE. These look like they hold")
+	_, _ = fmt.Fprintf(w, "  # sensitive values (%s), or are geography and geometry columns replaced by the\n",
+		strings.Join(SensitiveColumns, ", "))
+	_, _ = fmt.Fprintln(w, "  # transforms")
+
+	written := false
+	for _, schema := range database.Schemas {
+		for _, table := range schema.Tables {
+			var columns []string
+			for _, column := range table.Columns {
+				if isSensitive(column.ColumnName) || isSpatial(column) {
+					columns = append(columns, column.ColumnName)
+				}
+			}
+			if len(columns) == 0 {
+				continue
+			}
+
+			if !written {
+				_, _ = fmt.Fprintln(w, "  excluded_columns:")
+				written = true
+			}
+			_, _ = fmt.Fprintln(w, "    -")
+			_, _ = fmt.Fprintf(w, "      table: %s\n", strconv.Quote(table.TableSchema+"."+table.TableName))
+			_, _ = fmt.Fprintf(w, "      columns: %s\n", yamlList(columns))
+		}
+	}
+
+	if !written {
+		_, _ = fmt.Fprintln(w, "  #")
+		_, _ = fmt.Fprintln(w, "  # excluded_columns:")
+		_, _ = fmt.Fprintln(w, "  #   -")
+		_, _ = fmt.Fprintln(w, "  #     table: \"*.*\"")
+		_, _ = fmt.Fprintln(w, "  #     columns: [\"*_hash\"]")
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// writeScaffoldTransforms Reads every geography and geometry column as virtual lat and lon columns and writes it from
+// them. When a table has more than one, the others are prefixed with the name of the column
+func writeScaffoldTransforms(w io.Writer, database *Database) {
+	_, _ = fmt.Fprintln(w, "  # The geography and geometry columns are read as virtual lat and lon columns, which are")
+	_, _ = fmt.Fprintln(w, "  # written back to the column. Any column referenced in a transform must be preceded by a $")
+
+	written := false
+	for _, schema := range database.Schemas {
+		for _, table := range schema.Tables {
+			var selects, writes []Xform
+			for _, column := range table.Columns {
+				if !isSpatial(column) {
+					continue
+				}
+
+				lat, lon := "lat", "lon"
+				if len(selects) > 0 {
+					lat, lon = column.ColumnName+"_lat", column.ColumnName+"_lon"
+				}
+				geometry, point := column.ColumnName, fmt.Sprintf("ST_POINT($%s, $%s)", lon, lat)
+				if strings.HasPrefix(column.UdtName, "geography") {
+					geometry += "::geometry"
+					point += "::geography"
+				}
+				selects = append(selects,
+					Xform{Columnname: lat, Datatype: "decimal", Xform: fmt.Sprintf("ST_Y(%s)", geometry)},
+					Xform{Columnname: lon, Datatype: "decimal", Xform: fmt.Sprintf("ST_X(%s)", geometry)})
+				writes = append(writes, Xform{Columnname: column.ColumnName, Datatype: column.UdtName, Xform: point})
+			}
+			if len(selects) == 0 {
+				continue
+			}
+
+			if !written {
+				_, _ = fmt.Fprintln(w, "  transforms:")
+				written = true
+			}
+			_, _ = fmt.Fprintln(w, "    -")
+			_, _ = fmt.Fprintf(w, "      table: %s\n", strconv.Quote(table.TableSchema+"."+table.TableName))
+			_, _ = fmt.Fprintln(w, "      xforms:")
+			writeScaffoldXforms(w, "select", selects)
+			writeScaffoldXforms(w, "insert", writes)
+			writeScaffoldXforms(w, "update", writes)
+		}
+	}
+
+	if !written {
+		_, _ = fmt.Fprintln(w, "  #")
+		_, _ = fmt.Fprintln(w, "  # transforms:")
+		_, _ = fmt.Fprintln(w, "  #   -")
+		_, _ = fmt.Fprintln(w, "  #     table: \"public.account\"")
+		_, _ = fmt.Fprintln(w, "  #     xforms:")
+		_, _ = fmt.Fprintln(w, "  #       select:")
+		_, _ = fmt.Fprintln(w, "  #         -")
+		_, _ = fmt.Fprintln(w, "  #           column: \"lat\"")
+		_, _ = fmt.Fprintln(w, "  #           data_type: \"decimal\"")
+		_, _ = fmt.Fprintln(w, "  #           xform: \"ST_Y(geog::geometry)\"")
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+func writeScaffoldXforms(w io.Writer, operation string, xforms []Xform) {
+	_, _ = fmt.Fprintf(w, "        %s:\n", operation)
+	for _, xform := range xforms {
+		_, _ = fmt.Fprintln(w, "          -")
+		_, _ = fmt.Fprintf(w, "            column: %s\n", strconv.Quote(xform.Columnname))
+		_, _ = fmt.Fprintf(w, "            data_type: %s\n", strconv.Quote(xform.Datatype))
+		_, _ = fmt.Fprintf(w, "            xform: %s\n", strconv.Quote(xform.Xform))
+	}
+}
+
+func isSensitive(columnName string) bool {
+	for _, pattern := range SensitiveColumns {
+		if ok, _ := path.Match(pattern, strings.ToLower(columnName)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isSpatial(column Column) bool {
+	return strings.HasPrefix(column.UdtName, "geography") || strings.HasPrefix(column.UdtName, "geometry")
+}
+
+func yamlList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// dataSourceTemplate Returns the data source to write to the config, where any password is replaced by a reference to
+// the DBMAP_PASSWORD environment variable. Every other ${ is escaped so that it is not interpolated
+func dataSourceTemplate(dataSource string) (string, bool) {
+	const password = "${DBMAP_PASSWORD}"
+	escape := func(s string) string {
+		return strings.ReplaceAll(s, "${", "$${")
+	}
+
+	if u, err := url.Parse(dataSource); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.User(u.User.Username())
+			rest := strings.TrimPrefix(u.String(), u.Scheme+"://"+u.User.String()+"@")
+			return u.Scheme + "://" + escape(u.User.String()) + ":" + password + "@" + escape(rest), true
+		}
+		return escape(dataSource), false
+	}
+
+	if !dsnPassword.MatchString(dataSource) {
+		return escape(dataSource), false
+	}
+	return dsnPassword.ReplaceAllStringFunc(escape(dataSource), func(pair string) string {
+		return dsnPassword.FindStringSubmatch(pair)[1] + password
+	}), true
+}
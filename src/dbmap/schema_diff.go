@@ -0,0 +1,223 @@
+package dbmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Change A difference between two databases. Kind is added, removed or changed and Object is what changed: a table,
+// column, index or foreign key. A change is Breaking when the protos generated before and after it are not wire
+// compatible, e.g. a message or field was removed or a field changed its number or type
+type Change struct {
+	Kind     string
+	Object   string
+	Name     string
+	Detail   string
+	Breaking bool
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s %s", c.Kind, c.Object, c.Name)
+	if c.Detail != "" {
+		s += ": " + c.Detail
+	}
+	return s
+}
+
+// ReadSnapshot Reads a database that was saved as JSON by inspect --json
+func ReadSnapshot(filename string) (*Database, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var database Database
+	if err := json.Unmarshal(b, &database); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s : %w", filename, err)
+	}
+	return &database, nil
+}
+
+// DiffDatabases Returns the changes from one database to the other, ordered by schema, table and then the changes
+// to the columns, indexes and foreign keys of each table
+func DiffDatabases(from *Database, to *Database) []Change {
+	fromTables := tablesByName(from)
+	toTables := tablesByName(to)
+
+	changes := make([]Change, 0)
+	for _, name := range sortedTableNames(fromTables, toTables) {
+		fromTable, inFrom := fromTables[name]
+		toTable, inTo := toTables[name]
+		if !inTo {
+			changes = append(changes, Change{Kind: "removed", Object: "table", Name: name, Breaking: true})
+		} else if !inFrom {
+			changes = append(changes, Change{Kind: "added", Object: "table", Name: name})
+		} else {
+			changes = append(changes, diffColumns(name, fromTable, toTable)...)
+			changes = append(changes, diffIndexes(name, fromTable, toTable)...)
+			changes = append(changes, diffRelations(name, fromTable, toTable)...)
+		}
+	}
+	return changes
+}
+
+func tablesByName(database *Database) map[string]Table {
+	tables := make(map[string]Table)
+	for _, schema := range database.Schemas {
+		for _, table := range schema.Tables {
+			tables[table.TableSchema+"."+table.TableName] = table
+		}
+	}
+	return tables
+}
+
+func sortedTableNames(from map[string]Table, to map[string]Table) []string {
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// diffColumns Compares the columns of the table in the order of the new table, followed by the removed columns. The
+// field number of a column in the proto is its position in the table, so adding a column anywhere but the end or
+// removing a column renumbers the columns after it
+func diffColumns(tableName string, from Table, to Table) []Change {
+	fromColumns := make(map[string]int)
+	for i, column := range from.Columns {
+		fromColumns[column.ColumnName] = i
+	}
+	toColumns := make(map[string]int)
+	for i, column := range to.Columns {
+		toColumns[column.ColumnName] = i
+	}
+
+	changes := make([]Change, 0)
+	for i, column := range to.Columns {
+		name := tableName + "." + column.ColumnName
+		j, ok := fromColumns[column.ColumnName]
+		if !ok {
+			changes = append(changes, Change{Kind: "added", Object: "column", Name: name,
+				Detail: fmt.Sprintf("%s, field %d", inspectType(column), i+1), Breaking: i < len(from.Columns)})
+			continue
+		}
+
+		details := make([]string, 0)
+		breaking := false
+		old := from.Columns[j]
+		if i != j {
+			details = append(details, fmt.Sprintf("field %d -> %d", j+1, i+1))
+			breaking = true
+		}
+		if inspectType(old) != inspectType(column) {
+			details = append(details, fmt.Sprintf("type %s -> %s", inspectType(old), inspectType(column)))
+			breaking = breaking || protoType(old) != protoType(column) ||
+				(old.DataType == "ARRAY") != (column.DataType == "ARRAY")
+		}
+		if old.IsNullable != column.IsNullable {
+			details = append(details, fmt.Sprintf("nullable %t -> %t", old.IsNullable, column.IsNullable))
+		}
+		if old.ColumnDefault != column.ColumnDefault {
+			details = append(details, fmt.Sprintf("default %q -> %q", old.ColumnDefault, column.ColumnDefault))
+		}
+		if old.IsPrimaryKey != column.IsPrimaryKey {
+			details = append(details, fmt.Sprintf("primary key %t -> %t", old.IsPrimaryKey, column.IsPrimaryKey))
+		}
+
+		if len(details) > 0 {
+			changes = append(changes, Change{Kind: "changed", Object: "column", Name: name,
+				Detail: strings.Join(details, ", "), Breaking: breaking})
+		}
+	}
+
+	for _, column := range from.Columns {
+		if _, ok := toColumns[column.ColumnName]; !ok {
+			changes = append(changes, Change{Kind: "removed", Object: "column", Name: tableName + "." + column.ColumnName,
+				Detail: inspectType(column), Breaking: true})
+		}
+	}
+	return changes
+}
+
+// diffIndexes Compares the indexes by name. Indexes are not part of the proto, so an index change is never breaking
+func diffIndexes(tableName string, from Table, to Table) []Change {
+	describe := func(index Index) string {
+		s := fmt.Sprintf("%s %s (%s)", indexTypes[index.IndexType], index.Method, strings.Join(index.Columns, ", "))
+		if len(index.Include) > 0 {
+			s += fmt.Sprintf(" INCLUDE (%s)", strings.Join(index.Include, ", "))
+		}
+		if index.Predicate != "" {
+			s += " WHERE " + index.Predicate
+		}
+		return s
+	}
+
+	fromIndexes := make(map[string]string)
+	for _, index := range from.Indexes {
+		fromIndexes[index.IndexName] = describe(index)
+	}
+	toIndexes := make(map[string]string)
+	for _, index := range to.Indexes {
+		toIndexes[index.IndexName] = describe(index)
+	}
+	return diffDescriptions(tableName, "index", fromIndexes, toIndexes, false)
+}
+
+// diffRelations Compares the foreign keys by the table they reference and their local columns. When relationships are
+// embedded a foreign key is a field of the proto, so removing one is flagged as breaking
+func diffRelations(tableName string, from Table, to Table) []Change {
+	describe := func(relations []ForeignRelation) map[string]string {
+		descriptions := make(map[string]string)
+		for i := range relations {
+			relation := &relations[i]
+			name := fmt.Sprintf("(%s) => %s.%s", getLocalKeys(relation), relation.ForeignSchema, relation.ForeignTable)
+			foreign := make([]string, 0)
+			for _, column := range relation.Columns {
+				foreign = append(foreign, column.ForeignColumn)
+			}
+			descriptions[name] = "(" + strings.Join(foreign, ", ") + ")"
+		}
+		return descriptions
+	}
+	return diffDescriptions(tableName, "foreign key", describe(from.Relations), describe(to.Relations), true)
+}
+
+func diffDescriptions(tableName string, object string, from map[string]string, to map[string]string,
+	removedBreaking bool) []Change {
+	names := make([]string, 0)
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]Change, 0)
+	for _, name := range names {
+		old, inFrom := from[name]
+		description, inTo := to[name]
+		if !inTo {
+			changes = append(changes, Change{Kind: "removed", Object: object, Name: tableName + " " + name,
+				Detail: old, Breaking: removedBreaking})
+		} else if !inFrom {
+			changes = append(changes, Change{Kind: "added", Object: object, Name: tableName + " " + name,
+				Detail: description})
+		} else if old != description {
+			changes = append(changes, Change{Kind: "changed", Object: object, Name: tableName + " " + name,
+				Detail: old + " -> " + description})
+		}
+	}
+	return changes
+}
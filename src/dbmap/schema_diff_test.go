@@ -0,0 +1,78 @@
+package dbmap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func diffTable(columns ...Column) Table {
+	return Table{TableSchema: "test_schema", TableName: "user", Columns: columns,
+		Indexes: []Index{{IndexName: "user_email_idx", IndexType: Unique, Columns: []string{"email"}, Method: "btree"}}}
+}
+
+func TestDiffDatabases(t *testing.T) {
+	userID := Column{ColumnName: "user_id", UdtName: "int8", DataType: "bigint"}
+	email := Column{ColumnName: "email", UdtName: "varchar", DataType: "character varying"}
+	from := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{
+		diffTable(userID, email),
+		{TableSchema: "test_schema", TableName: "dropped"},
+	}}}}
+
+	text := email
+	text.UdtName = "text"
+	text.IsNullable = true
+	to := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{
+		diffTable(userID, text, Column{ColumnName: "name", UdtName: "int4"}),
+	}}}}
+	to.Schemas[0].Tables[0].Indexes = nil
+
+	expected := []Change{
+		{Kind: "removed", Object: "table", Name: "test_schema.dropped", Breaking: true},
+		{Kind: "changed", Object: "column", Name: "test_schema.user.email",
+			Detail: "type varchar -> text, nullable false -> true"},
+		{Kind: "added", Object: "column", Name: "test_schema.user.name", Detail: "int4, field 3"},
+		{Kind: "removed", Object: "index", Name: "test_schema.user user_email_idx", Detail: "UNIQUE btree (email)"},
+	}
+
+	changes := DiffDatabases(from, to)
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes but got %v", len(expected), changes)
+	}
+	for i, change := range changes {
+		if change != expected[i] {
+			t.Errorf("%d) Expected %+v but got %+v", i, expected[i], change)
+		}
+	}
+
+	// Inserting a column renumbers the fields after it and changing the proto type is not wire compatible
+	from.Schemas[0].Tables[0].Columns = []Column{userID, email}
+	to.Schemas[0].Tables[0].Columns = []Column{{ColumnName: "name", UdtName: "int4"}, {ColumnName: "user_id",
+		UdtName: "int4"}, email}
+	for _, change := range DiffDatabases(from, to) {
+		if change.Object == "column" && !change.Breaking {
+			t.Errorf("Expected %s to be breaking", change)
+		}
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	database := inspectDatabase()
+	filename := filepath.Join(t.TempDir(), "snapshot.json")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InspectJSON(f, database); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	snapshot, err := ReadSnapshot(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := DiffDatabases(database, snapshot); len(changes) != 0 {
+		t.Errorf("Expected no changes but got %v", changes)
+	}
+}
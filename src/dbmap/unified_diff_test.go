@@ -0,0 +1,39 @@
+package dbmap
+
+import (
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff := UnifiedDiff("a", "b", "same\n", "same\n"); diff != "" {
+		t.Errorf("Expected no diff but got %s", diff)
+	}
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+		"@@ -13,3 +13,4 @@\n 13\n 14\n 15\n+16\n"
+	if diff := UnifiedDiff("a", "b", a, b); diff != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, diff)
+	}
+
+	expected = "--- /dev/null\n+++ b\n@@ -0,0 +1,2 @@\n+1\n+2\n"
+	if diff := UnifiedDiff("/dev/null", "b", "", "1\n2\n"); diff != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, diff)
+	}
+
+	// Only the newline at the end differs, which diff(1) shows as a change of the last line
+	expected = "--- a\n+++ b\n@@ -1,2 +1,2 @@\n 1\n-2\n\\ No newline at end of file\n+2\n"
+	if diff := UnifiedDiff("a", "b", "1\n2", "1\n2\n"); diff != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, diff)
+	}
+	expected = "--- a\n+++ b\n@@ -1,2 +1,2 @@\n 1\n-2\n+2\n\\ No newline at end of file\n"
+	if diff := UnifiedDiff("a", "b", "1\n2\n", "1\n2"); diff != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, diff)
+	}
+	expected = "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+one\n 2\n\\ No newline at end of file\n"
+	if diff := UnifiedDiff("a", "b", "1\n2", "one\n2"); diff != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, diff)
+	}
+}
@@ -0,0 +1,480 @@
+package dbmap
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigError A problem with the configuration. Line is the line of the YAML the problem was found on, or 0 when the
+// configuration was not read from a file
+type ConfigError struct {
+	Line    int
+	Field   string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	s := e.Message
+	if e.Field != "" {
+		s = e.Field + ": " + s
+	}
+	if e.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", e.Line, s)
+	}
+	return s
+}
+
+// ConfigErrors Every problem found while reading the configuration
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	problems := make([]string, 0, len(e))
+	for _, err := range e {
+		problems = append(problems, err.Error())
+	}
+	return strings.Join(problems, "\n")
+}
+
+// The errors of yaml.v3 are prefixed with the line and name the (anonymous) struct type, which is replaced by the name
+// of the field
+var (
+	yamlLine       = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	yamlNotFound   = regexp.MustCompile(`^field (\S+) not found in type .*$`)
+	yamlUnmarshal  = regexp.MustCompile(`^cannot unmarshal (\S+) (.*) into .*$`)
+	queryParameter = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)(?::([A-Za-z0-9_.\[\]]*))?`)
+	xformReference = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+	identifier     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	qualifiedName  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+)
+
+// The types that a parameter of a mapping query can be declared as, e.g. $email:string
+var parameterTypes = map[string]bool{
+	"string": true, "bool": true, "bytes": true, "[]byte": true, "time": true, "time.Time": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true,
+}
+
+// yamlErrors Converts the errors of decoding the YAML to ConfigErrors
+func yamlErrors(err error) ConfigErrors {
+	messages := []string{err.Error()}
+	if typeError, ok := err.(*yaml.TypeError); ok {
+		messages = typeError.Errors
+	}
+
+	problems := make(ConfigErrors, 0, len(messages))
+	for _, message := range messages {
+		problem := ConfigError{Message: message}
+		if m := yamlLine.FindStringSubmatch(message); m != nil {
+			problem.Line, _ = strconv.Atoi(m[1])
+			problem.Message = m[2]
+		}
+
+		if m := yamlNotFound.FindStringSubmatch(problem.Message); m != nil {
+			problem.Field = m[1]
+			problem.Message = "unknown field"
+		} else if m := yamlUnmarshal.FindStringSubmatch(problem.Message); m != nil {
+			problem.Message = fmt.Sprintf("invalid value %s (%s)", m[2], strings.TrimPrefix(m[1], "!!"))
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// line Returns the line of the field in the YAML. The path is the keys of the mappings and the indexes of the
+// sequences leading to the field, and the line of the nearest parent is returned when the field is missing
+func (cfg Config) line(path ...string) int {
+	if cfg.node == nil {
+		return 0
+	}
+
+	node := cfg.node
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	line := node.Line
+	for _, key := range path {
+		var next *yaml.Node
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					next = node.Content[i+1]
+					line = node.Content[i].Line
+					break
+				}
+			}
+		} else if i, err := strconv.Atoi(key); err == nil && node.Kind == yaml.SequenceNode && i < len(node.Content) {
+			next = node.Content[i]
+			line = next.Line
+		}
+
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return line
+}
+
+func (cfg Config) problem(message string, path ...string) ConfigError {
+	return ConfigError{Line: cfg.line(path...), Field: strings.Join(path, "."), Message: message}
+}
+
+// Validate Returns every problem found with the configuration, or nil when it can be used to generate code. The
+// tables and columns the configuration refers to are checked by ValidateDatabase once the database has been read
+func (cfg Config) Validate() []error {
+	var problems []error
+	if cfg.Database.Provider != "postgres" && cfg.Database.Provider != "mariadb" {
+		problems = append(problems, cfg.problem(fmt.Sprintf("must be postgres or mariadb, got %q",
+			cfg.Database.Provider), "database", "provider"))
+	}
+	problems = append(problems, cfg.validateConnection()...)
+	if cfg.Database.MaxConnections < 0 {
+		problems = append(problems, cfg.problem("must not be negative", "database", "max_connections"))
+	}
+	if cfg.Output.Path == "" {
+		problems = append(problems, cfg.problem("must have a value", "output", "path"))
+	}
+	if cfg.Proto.Path == "" {
+		problems = append(problems, cfg.problem("must have a value", "proto", "path"))
+	}
+	if cfg.Proto.Version != "proto2" && cfg.Proto.Version != "proto3" {
+		problems = append(problems, cfg.problem(fmt.Sprintf("must be proto2 or proto3, got %q", cfg.Proto.Version),
+			"proto", "version"))
+	}
+	if len(cfg.Generator.Schemas) == 0 {
+		problems = append(problems, cfg.problem("must specify at least one schema", "generator", "schemas"))
+	}
+
+	problems = append(problems, cfg.validateGenerators()...)
+	problems = append(problems, cfg.validatePatterns()...)
+	problems = append(problems, cfg.validateMappings()...)
+	problems = append(problems, cfg.validateTransforms()...)
+	problems = append(problems, cfg.validateOverrides()...)
+	return problems
+}
+
+func (cfg Config) validateConnection() []error {
+	var problems []error
+	if cfg.Database.DSN != "" {
+		// The dsn replaces every other connection option, so any that are given would be ignored
+		for field, value := range map[string]string{"host": cfg.Database.Host, "port": cfg.Database.Port,
+			"database": cfg.Database.Database, "user": cfg.Database.Username, "password": cfg.Database.Password,
+			"sslmode": cfg.Database.SSLMode} {
+			if value != "" {
+				problems = append(problems, cfg.problem("cannot be combined with database.dsn", "database", field))
+			}
+		}
+		sort.Slice(problems, func(i, j int) bool {
+			return problems[i].(ConfigError).Line < problems[j].(ConfigError).Line
+		})
+		return problems
+	}
+
+	if cfg.Database.Host == "" {
+		problems = append(problems, cfg.problem("must have a value", "database", "host"))
+	}
+	if cfg.Database.Port != "" {
+		if port, err := strconv.Atoi(cfg.Database.Port); err != nil || port <= 0 || port > 65535 {
+			problems = append(problems, cfg.problem(fmt.Sprintf("must be a port number, got %q", cfg.Database.Port),
+				"database", "port"))
+		}
+	}
+	if cfg.Database.Database == "" {
+		problems = append(problems, cfg.problem("must have a value", "database", "database"))
+	}
+	if cfg.Database.Username == "" {
+		problems = append(problems, cfg.problem("must have a value", "database", "user"))
+	}
+	if cfg.Database.SSLMode != "" && !sslModes[cfg.Database.SSLMode] {
+		problems = append(problems, cfg.problem(fmt.Sprintf("must be one of disable, allow, prefer, require, "+
+			"verify-ca or verify-full, got %q", cfg.Database.SSLMode), "database", "sslmode"))
+	}
+	return problems
+}
+
+func (cfg Config) validateGenerators() []error {
+	var problems []error
+	for i, entry := range cfg.Output.Generators {
+		field := []string{"output", "generators", strconv.Itoa(i)}
+		if entry.Name == "" {
+			problems = append(problems, cfg.problem("must have a name", field...))
+			continue
+		}
+		if _, ok := LookupGenerator(entry.Name); ok || entry.Command != "" {
+			continue
+		}
+		if _, err := exec.LookPath("go_dbmap-gen-" + entry.Name); err != nil {
+			problems = append(problems, cfg.problem(fmt.Sprintf("%s is not one of the generators (%s) and "+
+				"go_dbmap-gen-%s was not found on the PATH", entry.Name, strings.Join(RegisteredGenerators(), ", "),
+				entry.Name), field...))
+		}
+	}
+	return problems
+}
+
+func (cfg Config) validatePatterns() []error {
+	var problems []error
+	check := func(pattern string, field ...string) {
+		if isRegexp(pattern) {
+			if _, err := regexp.Compile(pattern[1 : len(pattern)-1]); err != nil {
+				problems = append(problems, cfg.problem(fmt.Sprintf("invalid regular expression %s", pattern),
+					field...))
+			}
+		} else if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			problems = append(problems, cfg.problem(fmt.Sprintf("invalid pattern %q", pattern), field...))
+		}
+	}
+
+	for i, pattern := range cfg.Generator.IncludedTables {
+		check(pattern, "generator", "included_tables", strconv.Itoa(i))
+	}
+	for i, pattern := range cfg.Generator.ExcludedTables {
+		check(pattern, "generator", "excluded_tables", strconv.Itoa(i))
+	}
+	for i, excludedColumn := range cfg.Generator.ExcludedColumns {
+		check(excludedColumn.Tablename, "generator", "excluded_columns", strconv.Itoa(i), "table")
+		for j, pattern := range excludedColumn.Columns {
+			check(pattern, "generator", "excluded_columns", strconv.Itoa(i), "columns", strconv.Itoa(j))
+		}
+	}
+	for i, override := range cfg.Generator.Tables {
+		if override.Tablename != "" {
+			check(override.Tablename, "generator", "tables", strconv.Itoa(i), "table")
+		}
+	}
+	return problems
+}
+
+func (cfg Config) validateMappings() []error {
+	var problems []error
+	for i, mapping := range cfg.Generator.Mapping {
+		if mapping.Tablename == "" {
+			problems = append(problems, cfg.problem("must have a value", "generator", "mapping", strconv.Itoa(i),
+				"table"))
+		}
+
+		names := make(map[string]bool)
+		for j, query := range mapping.Queries {
+			path := []string{"generator", "mapping", strconv.Itoa(i), "queries", strconv.Itoa(j)}
+			if query.Name == "" {
+				problems = append(problems, cfg.problem("must have a value", append(path, "name")...))
+			} else if names[query.Name] {
+				problems = append(problems, cfg.problem(fmt.Sprintf("duplicate query %s", query.Name),
+					append(path, "name")...))
+			}
+			names[query.Name] = true
+
+			if query.Query == "" {
+				problems = append(problems, cfg.problem("must have a value", append(path, "query")...))
+			}
+			for _, m := range queryParameter.FindAllStringSubmatch(query.Query, -1) {
+				if m[2] == "" {
+					problems = append(problems, cfg.problem(fmt.Sprintf("parameter $%s must declare its type, "+
+						"like $%s:string", m[1], m[1]), append(path, "query")...))
+				} else if !parameterTypes[m[2]] {
+					problems = append(problems, cfg.problem(fmt.Sprintf("parameter $%s has an unknown type %s",
+						m[1], m[2]), append(path, "query")...))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func (cfg Config) validateTransforms() []error {
+	var problems []error
+	for i, transform := range cfg.Generator.Transforms {
+		path := []string{"generator", "transforms", strconv.Itoa(i)}
+		if transform.Tablename == "" {
+			problems = append(problems, cfg.problem("must have a value", append(path, "table")...))
+		}
+
+		for j, xform := range transform.Xforms.Select {
+			xformPath := append(append([]string{}, path...), "xforms", "select", strconv.Itoa(j))
+			if xform.Columnname == "" {
+				problems = append(problems, cfg.problem("must have a value", append(xformPath, "column")...))
+			}
+			if _, ok := sqlToProtoType(xform.Datatype); !ok {
+				problems = append(problems, cfg.problem(fmt.Sprintf("unknown data type %q", xform.Datatype),
+					append(xformPath, "data_type")...))
+			}
+		}
+	}
+	return problems
+}
+
+func (cfg Config) validateOverrides() []error {
+	var problems []error
+	checkName := func(name string, pattern *regexp.Regexp, field ...string) {
+		if name != "" && !pattern.MatchString(name) {
+			problems = append(problems, cfg.problem(fmt.Sprintf("%q is not a valid name", name), field...))
+		}
+	}
+
+	for i, override := range cfg.Generator.Tables {
+		path := []string{"generator", "tables", strconv.Itoa(i)}
+		if override.Tablename == "" {
+			problems = append(problems, cfg.problem("must have a value", append(path, "table")...))
+		}
+		checkName(override.GoName, identifier, append(path, "go_name")...)
+		checkName(override.Message, identifier, append(path, "message")...)
+
+		for j, operation := range override.Operations {
+			known := false
+			for _, o := range Operations {
+				known = known || o == operation
+			}
+			if !known {
+				problems = append(problems, cfg.problem(fmt.Sprintf("unknown operation %q, must be one of %s",
+					operation, strings.Join(Operations, ", ")), append(path, "operations", strconv.Itoa(j))...))
+			}
+		}
+
+		for j, column := range override.Columns {
+			columnPath := append(append([]string{}, path...), "columns", strconv.Itoa(j))
+			if column.Columnname == "" {
+				problems = append(problems, cfg.problem("must have a value", append(columnPath, "column")...))
+			}
+			checkName(column.GoName, identifier, append(columnPath, "go_name")...)
+			checkName(column.Field, identifier, append(columnPath, "field")...)
+			checkName(column.JSON, identifier, append(columnPath, "json")...)
+			checkName(column.ProtoType, qualifiedName, append(columnPath, "proto_type")...)
+		}
+	}
+	return problems
+}
+
+// ValidateDatabase Returns every problem with the tables and columns that the mappings and transforms refer to, which
+// must exist in the database that was read. A column referenced as $column by an insert or update transform must be
+// a column of the table or a column produced by a select transform
+func (cfg Config) ValidateDatabase(database *Database) []error {
+	var problems []error
+	for i, mapping := range cfg.Generator.Mapping {
+		if len(findTables(database, mapping.Tablename)) == 0 {
+			problems = append(problems, cfg.problem(fmt.Sprintf("table %s was not found in the schemas that were read",
+				mapping.Tablename), "generator", "mapping", strconv.Itoa(i), "table"))
+		}
+	}
+
+	for i, override := range cfg.Generator.Tables {
+		path := []string{"generator", "tables", strconv.Itoa(i)}
+		tables := findTables(database, override.Tablename)
+		if len(tables) == 0 && override.Tablename != "" {
+			problems = append(problems, cfg.problem(fmt.Sprintf("table %s was not found in the schemas that were read",
+				override.Tablename), append(path, "table")...))
+		}
+
+		for _, table := range tables {
+			if override.OrderBy != "" {
+				if _, err := cfg.ListOrder(table); err != nil {
+					problems = append(problems, cfg.problem(err.Error(), append(path, "order_by")...))
+				}
+			}
+			if override.VersionColumn != "" {
+				if column, ok := cfg.VersionColumn(table); !ok {
+					problems = append(problems, cfg.problem(fmt.Sprintf("%s.%s has no column %s", table.TableSchema,
+						table.TableName, override.VersionColumn), append(path, "version_column")...))
+				} else if !IsVersionType(column) {
+					problems = append(problems, cfg.problem(fmt.Sprintf("%s of %s.%s must be an integer or a timestamp",
+						override.VersionColumn, table.TableSchema, table.TableName), append(path, "version_column")...))
+				}
+			}
+			for j, column := range override.UpsertColumns {
+				if !hasColumn(table, column) && !isSkippedColumn(table, column) {
+					problems = append(problems, cfg.problem(fmt.Sprintf("%s.%s has no column %s", table.TableSchema,
+						table.TableName, column), append(path, "upsert_columns", strconv.Itoa(j))...))
+				}
+			}
+			for j, column := range override.Columns {
+				if !hasColumn(table, column.Columnname) {
+					problems = append(problems, cfg.problem(fmt.Sprintf("%s.%s has no column %s", table.TableSchema,
+						table.TableName, column.Columnname), append(path, "columns", strconv.Itoa(j), "column")...))
+				}
+			}
+		}
+	}
+
+	for i, transform := range cfg.Generator.Transforms {
+		path := []string{"generator", "transforms", strconv.Itoa(i)}
+		tables := findTables(database, transform.Tablename)
+		if len(tables) == 0 {
+			problems = append(problems, cfg.problem(fmt.Sprintf("table %s was not found in the schemas that were read",
+				transform.Tablename), append(path, "table")...))
+			continue
+		}
+
+		for _, table := range tables {
+			// An excluded column, such as a geography written from the virtual lat and lon, can still be written
+			columns := make(map[string]bool)
+			for _, column := range table.Columns {
+				columns[column.ColumnName] = true
+			}
+			for _, column := range table.SkippedColumns {
+				columns[column] = true
+			}
+			virtual := make(map[string]bool)
+			for _, xform := range transform.Xforms.Select {
+				virtual[xform.Columnname] = true
+			}
+
+			operations := map[string][]Xform{"insert": transform.Xforms.Insert, "update": transform.Xforms.Update}
+			for _, operation := range []string{"insert", "update"} {
+				for j, xform := range operations[operation] {
+					xformPath := append(append([]string{}, path...), "xforms", operation, strconv.Itoa(j))
+					if !columns[xform.Columnname] {
+						problems = append(problems, cfg.problem(fmt.Sprintf("%s.%s has no column %s",
+							table.TableSchema, table.TableName, xform.Columnname), append(xformPath, "column")...))
+					}
+					for _, m := range xformReference.FindAllStringSubmatch(xform.Xform, -1) {
+						if !columns[m[1]] && !virtual[m[1]] {
+							problems = append(problems, cfg.problem(fmt.Sprintf("$%s is not a column of %s.%s or "+
+								"a select transform", m[1], table.TableSchema, table.TableName),
+								append(xformPath, "xform")...))
+						}
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// findTables Returns the tables matching the pattern
+func findTables(database *Database, pattern string) []Table {
+	tables := make([]Table, 0)
+	for _, schema := range database.Schemas {
+		for _, table := range schema.Tables {
+			if MatchTable(pattern, table.TableSchema, table.TableName) {
+				tables = append(tables, table)
+			}
+		}
+	}
+	return tables
+}
+
+func hasColumn(table Table, columnName string) bool {
+	for _, column := range table.Columns {
+		if column.ColumnName == columnName {
+			return true
+		}
+	}
+	return false
+}
+
+// isSkippedColumn Returns true if the column was excluded, which can still be written by an insert transform
+func isSkippedColumn(table Table, columnName string) bool {
+	for _, column := range table.SkippedColumns {
+		if column == columnName {
+			return true
+		}
+	}
+	return false
+}
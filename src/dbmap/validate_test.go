@@ -0,0 +1,122 @@
+package dbmap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, yaml string) string {
+	filename := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(filename, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+const validConfig = `database:
+  provider: "postgres"
+  host: "localhost"
+  port: 5432
+  database: "dbmap_test"
+  user: "dbmap_test"
+output:
+  path: "output"
+proto:
+  path: "output/proto"
+  version: "proto3"
+generator:
+  schemas: ["test_schema"]
+  mapping:
+    -
+      table: "test_schema.user"
+      queries:
+        -
+          name: "get_pword_hash"
+          query: "SELECT pword_hash FROM test_schema.user WHERE email = $email:string"
+  transforms:
+    -
+      table: "test_schema.user"
+      xforms:
+        select:
+          -
+            column: "lat"
+            data_type: "decimal"
+            xform: "ST_Y(geog::geometry)"
+        insert:
+          -
+            column: "geog"
+            data_type: "geography"
+            xform: "ST_POINT($lon, $lat)::geography"
+`
+
+func TestReadFileUnknownFields(t *testing.T) {
+	filename := writeConfig(t, strings.Replace(validConfig, "  schemas:", "  excluded_table: [\"foo\"]\n  schemas:", 1))
+
+	var cfg Config
+	err := ReadFile(&cfg, filename)
+	problems, ok := err.(ConfigErrors)
+	if !ok || len(problems) != 1 {
+		t.Fatalf("Expected one problem but got %v", err)
+	}
+	if expected := "line 13: excluded_table: unknown field"; problems[0].Error() != expected {
+		t.Errorf("Expected %s but got %s", expected, problems[0])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var cfg Config
+	if err := ReadFile(&cfg, writeConfig(t, validConfig)); err != nil {
+		t.Fatal(err)
+	}
+	if problems := cfg.Validate(); len(problems) != 0 {
+		t.Fatalf("Expected no problems but got %v", problems)
+	}
+
+	bad := strings.Replace(validConfig, "proto3", "proto4", 1)
+	bad = strings.Replace(bad, "$email:string", "$email:varchar", 1)
+	bad = strings.Replace(bad, "decimal", "point", 1)
+	if err := ReadFile(&cfg, writeConfig(t, bad)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`line 11: proto.version: must be proto2 or proto3, got "proto4"`,
+		"line 20: generator.mapping.0.queries.0.query: parameter $email has an unknown type varchar",
+		`line 28: generator.transforms.0.xforms.select.0.data_type: unknown data type "point"`,
+	}
+	problems := cfg.Validate()
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems but got %v", len(expected), problems)
+	}
+	for i, problem := range problems {
+		if problem.Error() != expected[i] {
+			t.Errorf("%d) Expected %s but got %s", i, expected[i], problem)
+		}
+	}
+}
+
+func TestValidateDatabase(t *testing.T) {
+	var cfg Config
+	if err := ReadFile(&cfg, writeConfig(t, validConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	table := Table{TableSchema: "test_schema", TableName: "user", Columns: []Column{{ColumnName: "geog"}}}
+	database := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{table}}}}
+
+	expected := []string{
+		"line 34: generator.transforms.0.xforms.insert.0.xform: $lon is not a column of test_schema.user or a " +
+			"select transform",
+	}
+	problems := cfg.ValidateDatabase(database)
+	if len(problems) != len(expected) || problems[0].Error() != expected[0] {
+		t.Errorf("Expected %v but got %v", expected, problems)
+	}
+
+	database.Schemas[0].Tables[0].TableName = "account"
+	if problems := cfg.ValidateDatabase(database); len(problems) != 2 {
+		t.Errorf("Expected the mapping and transform tables to be missing but got %v", problems)
+	}
+}
@@ -0,0 +1,141 @@
+package dbmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// TableChanges The tables that differ between two reads of the database. Changed holds the added and altered tables as
+// they are now, and Removed the schema qualified names of the tables that were dropped or are no longer read
+type TableChanges struct {
+	Changed []Table
+	Removed []string
+}
+
+// Empty Returns true when no table changed
+func (c TableChanges) Empty() bool {
+	return len(c.Changed) == 0 && len(c.Removed) == 0
+}
+
+// ChangedTables Returns the tables of the database that were added, altered or removed since it was last read, ordered
+// by their schema qualified name
+func ChangedTables(from *Database, to *Database) TableChanges {
+	fromTables := tablesByName(from)
+	toTables := tablesByName(to)
+
+	var changes TableChanges
+	for _, name := range sortedTableNames(fromTables, toTables) {
+		fromTable, inFrom := fromTables[name]
+		toTable, inTo := toTables[name]
+		if !inTo {
+			changes.Removed = append(changes.Removed, name)
+		} else if !inFrom || !reflect.DeepEqual(fromTable, toTable) {
+			changes.Changed = append(changes.Changed, toTable)
+		}
+	}
+	return changes
+}
+
+// SubsetDatabase Returns a copy of the database holding only the given tables, so that the generators only write the
+// files of those tables. The connection and the schemas are kept, even when none of their tables are included
+func SubsetDatabase(database *Database, tables []Table) *Database {
+	include := make(map[string]bool)
+	for _, table := range tables {
+		include[table.TableSchema+"."+table.TableName] = true
+	}
+
+	subset := Database{DB: database.DB, Schemas: make([]Schema, 0, len(database.Schemas))}
+	for _, schema := range database.Schemas {
+		s := Schema{SchemaName: schema.SchemaName, Tables: make([]Table, 0)}
+		for _, table := range schema.Tables {
+			if include[table.TableSchema+"."+table.TableName] {
+				s.Tables = append(s.Tables, table)
+			}
+		}
+		subset.Schemas = append(subset.Schemas, s)
+	}
+	return &subset
+}
+
+// Notifier Implemented by providers that can tell when the schemas may have changed, so that watching does not need to
+// poll. InstallEventTrigger installs what the database needs to send the notifications, which is only needed once
+type Notifier interface {
+	InstallEventTrigger(ctx context.Context) error
+	Listen(ctx context.Context) (<-chan struct{}, error)
+}
+
+// WatchOptions How Watch finds out that the database may have changed. The database is read again every Interval and
+// whenever Wake is signalled. With a zero Interval the database is only read when woken. A read that fails is reported
+// to Log, when it is given
+type WatchOptions struct {
+	Interval time.Duration
+	Wake     <-chan struct{}
+	Log      io.Writer
+}
+
+// Watch Reads the database and calls changed with every table, then reads it again whenever it may have changed and
+// calls changed with the tables that differ from the previous read. A failed read, such as one that raced a migration,
+// is reported and retried at the next interval. Watch returns nil once ctx is done, or the error of the first read or of
+// changed
+func Watch(ctx context.Context, provider Provider, opts WatchOptions,
+	changed func(database *Database, changes TableChanges) error) error {
+	if opts.Interval <= 0 && opts.Wake == nil {
+		return errors.New("watching needs an interval or notifications")
+	}
+
+	current, err := provider.ReadDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	if err := changed(current, ChangedTables(&Database{}, current)); err != nil {
+		return err
+	}
+
+	var tick <-chan time.Time
+	if opts.Interval > 0 {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	wake := opts.Wake
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+		case _, ok := <-wake:
+			if !ok {
+				if tick == nil {
+					return errors.New("the notifications of schema changes stopped")
+				}
+				wake = nil
+				continue
+			}
+		}
+
+		database, err := provider.ReadDatabase(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if opts.Log != nil {
+				_, _ = fmt.Fprintf(opts.Log, "FAILED reading the database, will retry: %s\n", err)
+			}
+			continue
+		}
+
+		changes := ChangedTables(current, database)
+		current = database
+		if changes.Empty() {
+			continue
+		}
+		if err := changed(database, changes); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package dbmap
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChangedTables(t *testing.T) {
+	user := Table{TableSchema: "test_schema", TableName: "user", Columns: []Column{{ColumnName: "user_id"}}}
+	foo := Table{TableSchema: "public", TableName: "foo", Columns: []Column{{ColumnName: "foo_id"}}}
+	from := &Database{Schemas: []Schema{{SchemaName: "public", Tables: []Table{foo}},
+		{SchemaName: "test_schema", Tables: []Table{user}}}}
+
+	if changes := ChangedTables(from, from); !changes.Empty() {
+		t.Errorf("Expected no changes but got %v", changes)
+	}
+
+	altered := user
+	altered.Columns = append([]Column{}, user.Columns...)
+	altered.Columns = append(altered.Columns, Column{ColumnName: "email"})
+	bar := Table{TableSchema: "public", TableName: "bar"}
+	to := &Database{Schemas: []Schema{{SchemaName: "public", Tables: []Table{bar}},
+		{SchemaName: "test_schema", Tables: []Table{altered}}}}
+
+	changes := ChangedTables(from, to)
+	if len(changes.Changed) != 2 || changes.Changed[0].TableName != "bar" || changes.Changed[1].TableName != "user" {
+		t.Errorf("Expected public.bar and test_schema.user to have changed but got %v", changes.Changed)
+	}
+	if !reflect.DeepEqual(changes.Removed, []string{"public.foo"}) {
+		t.Errorf("Expected public.foo to be removed but got %v", changes.Removed)
+	}
+
+	subset := SubsetDatabase(to, changes.Changed[1:])
+	if len(subset.Schemas) != 2 || len(subset.Schemas[0].Tables) != 0 || len(subset.Schemas[1].Tables) != 1 {
+		t.Errorf("Expected only test_schema.user in the subset but got %v", subset.Schemas)
+	}
+}
+
+// Returns each of the databases in turn, repeating the last one
+type sequenceProvider struct {
+	databases []*Database
+	reads     int
+}
+
+func (p *sequenceProvider) ReadDatabase(ctx context.Context) (*Database, error) {
+	i := p.reads
+	if i >= len(p.databases) {
+		i = len(p.databases) - 1
+	}
+	p.reads++
+	if p.databases[i] == nil {
+		return nil, errors.New("relation does not exist")
+	}
+	return p.databases[i], nil
+}
+
+func TestWatch(t *testing.T) {
+	user := Table{TableSchema: "test_schema", TableName: "user"}
+	altered := Table{TableSchema: "test_schema", TableName: "user", Columns: []Column{{ColumnName: "email"}}}
+	first := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{user}}}}
+	second := &Database{Schemas: []Schema{{SchemaName: "test_schema", Tables: []Table{altered}}}}
+	provider := &sequenceProvider{databases: []*Database{first, first, nil, second}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wake := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			wake <- struct{}{}
+		}
+	}()
+
+	var calls []TableChanges
+	err := Watch(ctx, provider, WatchOptions{Wake: wake}, func(database *Database, changes TableChanges) error {
+		calls = append(calls, changes)
+		if len(calls) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("Expected every table and then the altered table but got %v", calls)
+	}
+	if len(calls[0].Changed) != 1 || len(calls[1].Changed) != 1 || len(calls[1].Changed[0].Columns) != 1 {
+		t.Errorf("Expected test_schema.user each time but got %v", calls)
+	}
+	if provider.reads != 4 {
+		t.Errorf("Expected the failed read to be retried but the database was read %d times", provider.reads)
+	}
+
+	if err := Watch(ctx, provider, WatchOptions{}, nil); err == nil {
+		t.Error("Expected an error without an interval or notifications")
+	}
+}
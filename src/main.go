@@ -1,51 +1,483 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/bryanhughes/go_dbmap/src/dbmap"
 	"github.com/bryanhughes/go_dbmap/src/dbmap/mariadb"
 	"github.com/bryanhughes/go_dbmap/src/dbmap/postgres"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
 )
 
-func main() {
-	fmt.Println("Go DB Code Mapping")
-	fmt.Println("=========================================================================")
+// The options that can be given to every command. Any that are set override the values in the config file
+type options struct {
+	schemas  stringList
+	tables   stringList
+	out      string
+	protoOut string
+	json     bool
+	interval time.Duration
+	listen   bool
+	install  bool
+	dsn      string
+}
+
+type command struct {
+	description string
+	run         func(args []string, opts options) error
+}
 
+var commands = map[string]command{
+	"generate": {"Run the generators of output.generators, the protos and the code by default", withConfig(generate)},
+	"proto":    {"Generate the protos only", withConfig(generateProto)},
+	"code":     {"Generate the code only", withConfig(generateCode)},
+	"check":    {"Fail with a diff when the generated files on disk are out of date", withConfig(check)},
+	"init":     {"Write a starter config file for the database given by --dsn", initConfig},
+	"inspect":  {"Print the tables, columns, indexes and foreign keys read from the database", withConfig(inspect)},
+	"validate": {"Check the config file without connecting to the database", withConfig(validate)},
+	"diff":     {"Compare two databases, each a config file or an inspect --json snapshot", diff},
+	"watch":    {"Regenerate the files of the tables that change, until interrupted", withConfig(watch)},
+}
+
+// Returned by a command when it was not given the arguments it expects
+var errUsage = errors.New("usage")
+
+func main() {
 	args := os.Args[1:]
 	if len(args) < 1 {
 		showUsage()
 	}
 
-	var cfg dbmap.Config
-	configFile := args[0]
-	dbmap.ReadFile(&cfg, configFile)
-
-	var provider dbmap.Provider
-	if cfg.Database.Provider == "postgres" {
-		provider = &postgres.Provider{Config: cfg}
+	// For compatibility, go_dbmap <config-file> is the same as go_dbmap generate <config-file>
+	name := args[0]
+	if _, ok := commands[name]; ok {
+		args = args[1:]
+	} else if strings.HasPrefix(name, "-") {
+		showUsage()
 	} else {
-		provider = &mariadb.Provider{Config: cfg}
+		name = "generate"
 	}
-	fmt.Println("\nReading Schemas")
-	fmt.Println("=========================================================================")
-	database := provider.ReadDatabase()
 
-	fmt.Println("\nGenerating Protos")
-	fmt.Println("=========================================================================")
-	if err := dbmap.GenerateProto(cfg, database); err != nil {
-		os.Exit(-1)
+	var opts options
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Var(&opts.schemas, "schema", "Read only this schema instead of generator.schemas (repeatable)")
+	fs.Var(&opts.tables, "table", "Read only the tables matching this pattern, like included_tables (repeatable)")
+	fs.StringVar(&opts.out, "out", "", "Write the code to this path instead of output.path")
+	fs.StringVar(&opts.protoOut, "proto-out", "", "Write the protos to this path instead of proto.path")
+	fs.BoolVar(&opts.json, "json", false, "Print the inspected model, or the changes of diff, as JSON")
+	fs.DurationVar(&opts.interval, "interval", 2*time.Second, "How often watch reads the database")
+	fs.BoolVar(&opts.listen, "listen", false, "Have watch LISTEN for schema changes instead of polling")
+	fs.BoolVar(&opts.install, "install-trigger", false, "Install the event trigger that --listen needs")
+	fs.StringVar(&opts.dsn, "dsn", "", "The database that init writes a config file for")
+	fs.Usage = showUsage
+
+	positional := parseArgs(fs, args)
+
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Keep stdout clean for the model so that it can be piped
+	if opts.json {
+		out = os.Stderr
 	}
 
-	fmt.Println("\nGenerating Code")
-	fmt.Println("=========================================================================")
-	if err := dbmap.GenerateCode(cfg, database); err != nil {
+	fmt.Fprintln(out, "Go DB Code Mapping")
+	fmt.Fprintln(out, "=========================================================================")
+
+	err := commands[name].run(positional, opts)
+	stop()
+	if err == errUsage {
+		showUsage()
+	} else if err != nil {
+		fmt.Fprintln(out, err)
 		os.Exit(-1)
 	}
 }
 
+// Where the progress is written, which is stderr with --json so that stdout only has the JSON
+var out io.Writer = os.Stdout
+
+// Cancelled on an interrupt, so that reading the database stops
+var ctx context.Context
+
+// parseArgs Parses the flags, which may be given before or after the config file
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		_ = fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// withConfig Adapts a command that is run against a single config file
+func withConfig(run func(cfg dbmap.Config, opts options) error) func(args []string, opts options) error {
+	return func(args []string, opts options) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		cfg, err := loadConfig(args[0], opts)
+		if err != nil {
+			return err
+		}
+		return run(cfg, opts)
+	}
+}
+
+func loadConfig(configFile string, opts options) (dbmap.Config, error) {
+	cfg := dbmap.Config{Log: out}
+	if err := dbmap.ReadFile(&cfg, configFile); err != nil {
+		return cfg, err
+	}
+	applyOptions(&cfg, opts)
+
+	if problems := cfg.Validate(); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(out, problem)
+		}
+		return cfg, fmt.Errorf("%s has %d problems", configFile, len(problems))
+	}
+	return cfg, nil
+}
+
+func applyOptions(cfg *dbmap.Config, opts options) {
+	if len(opts.schemas) > 0 {
+		cfg.Generator.Schemas = opts.schemas
+	}
+	if len(opts.tables) > 0 {
+		cfg.Generator.IncludedTables = opts.tables
+	}
+	if opts.out != "" {
+		cfg.Output.Path = opts.out
+	}
+	if opts.protoOut != "" {
+		cfg.Proto.Path = opts.protoOut
+	}
+}
+
+func newProvider(cfg dbmap.Config) dbmap.Provider {
+	if cfg.Database.Provider == "postgres" {
+		return &postgres.Provider{Config: cfg}
+	}
+	return &mariadb.Provider{Config: cfg}
+}
+
+func readDatabase(cfg dbmap.Config) (*dbmap.Database, error) {
+	fmt.Fprintln(out, "\nReading Schemas")
+	fmt.Fprintln(out, "=========================================================================")
+	database, err := newProvider(cfg).ReadDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(out, "\nSkipped")
+	fmt.Fprintln(out, "=========================================================================")
+	dbmap.PrintSkipped(out, database)
+
+	if problems := cfg.ValidateDatabase(database); len(problems) > 0 {
+		fmt.Fprintln(out)
+		for _, problem := range problems {
+			fmt.Fprintln(out, problem)
+		}
+		return nil, fmt.Errorf("the config file has %d problems with the schemas that were read", len(problems))
+	}
+	return database, nil
+}
+
+func generate(cfg dbmap.Config, opts options) error {
+	database, err := readDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, generator := range cfg.Generators() {
+		if err := runGenerator(generator, cfg, database); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func generateProto(cfg dbmap.Config, opts options) error {
+	return generateOnly("proto", cfg)
+}
+
+func generateCode(cfg dbmap.Config, opts options) error {
+	return generateOnly("code", cfg)
+}
+
+func generateOnly(name string, cfg dbmap.Config) error {
+	database, err := readDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	generator, _ := dbmap.LookupGenerator(name)
+	return runGenerator(generator, cfg, database)
+}
+
+func runGenerator(generator dbmap.Generator, cfg dbmap.Config, database *dbmap.Database) error {
+	fmt.Fprintf(out, "\nGenerating %s\n", generator.Name())
+	fmt.Fprintln(out, "=========================================================================")
+	if err := generator.Generate(ctx, cfg, database, dbmap.DiskFileSystem{}); err != nil {
+		return fmt.Errorf("generator %s: %w", generator.Name(), err)
+	}
+	return nil
+}
+
+func inspect(cfg dbmap.Config, opts options) error {
+	database, err := readDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	if opts.json {
+		return dbmap.InspectJSON(os.Stdout, database)
+	}
+	return dbmap.Inspect(out, database)
+}
+
+func check(cfg dbmap.Config, opts options) error {
+	database, err := readDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nChecking Generated Files")
+	fmt.Fprintln(out, "=========================================================================")
+	drifts, err := dbmap.Check(ctx, cfg, database)
+	if err != nil {
+		return err
+	}
+
+	for _, drift := range drifts {
+		fmt.Fprint(out, drift.Diff)
+	}
+	if len(drifts) > 0 {
+		return fmt.Errorf("%d generated files are out of date, run go_dbmap generate", len(drifts))
+	}
+	fmt.Fprintln(out, "Generated files are up to date")
+	return nil
+}
+
+// diff Compares two databases, where each is read from a config file or from a snapshot saved by inspect --json. Fails
+// when any change is breaking so that it can be used to review migrations
+func diff(args []string, opts options) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	databases := make([]*dbmap.Database, 0, 2)
+	for _, source := range args {
+		database, err := readSource(source, opts)
+		if err != nil {
+			return err
+		}
+		databases = append(databases, database)
+	}
+
+	fmt.Fprintln(out, "\nChanges")
+	fmt.Fprintln(out, "=========================================================================")
+	changes := dbmap.DiffDatabases(databases[0], databases[1])
+	if opts.json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(changes); err != nil {
+			return err
+		}
+	}
+
+	breaking := 0
+	for _, change := range changes {
+		if change.Breaking {
+			breaking++
+			fmt.Fprintf(out, "BREAKING %s\n", change)
+		} else {
+			fmt.Fprintf(out, "         %s\n", change)
+		}
+	}
+	fmt.Fprintf(out, "%d changes, %d breaking\n", len(changes), breaking)
+
+	if breaking > 0 {
+		return fmt.Errorf("%d changes break the wire format of the generated protos", breaking)
+	}
+	return nil
+}
+
+// watch Generates every table and then keeps reading the database, regenerating the files of the tables that changed.
+// The database is polled every interval, or with --listen only read when the event trigger reports a DDL command
+func watch(cfg dbmap.Config, opts options) error {
+	provider := newProvider(cfg)
+	watchOpts := dbmap.WatchOptions{Interval: opts.interval, Log: out}
+	if opts.listen || opts.install {
+		notifier, ok := provider.(dbmap.Notifier)
+		if !ok {
+			return fmt.Errorf("the %s provider cannot listen for schema changes", cfg.Database.Provider)
+		}
+		if opts.install {
+			if err := notifier.InstallEventTrigger(ctx); err != nil {
+				return err
+			}
+		}
+
+		wake, err := notifier.Listen(ctx)
+		if err != nil {
+			return err
+		}
+		watchOpts = dbmap.WatchOptions{Wake: wake, Log: out}
+	}
+
+	fmt.Fprintln(out, "\nWatching Schemas")
+	fmt.Fprintln(out, "=========================================================================")
+	return dbmap.Watch(ctx, provider, watchOpts, func(database *dbmap.Database, changes dbmap.TableChanges) error {
+		fmt.Fprintf(out, "\n[%s] %d tables changed, %d removed\n", time.Now().Format(time.Kitchen),
+			len(changes.Changed), len(changes.Removed))
+		for _, table := range changes.Changed {
+			fmt.Fprintf(out, "    %s.%s\n", table.TableSchema, table.TableName)
+		}
+		for _, name := range changes.Removed {
+			fmt.Fprintf(out, "    %s was removed, delete its generated files\n", name)
+		}
+
+		// A problem is reported but does not stop watching, since the next migration may well fix it
+		if problems := cfg.ValidateDatabase(database); len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Fprintln(out, problem)
+			}
+			fmt.Fprintf(out, "Not generating, the config file has %d problems with the schemas that were read\n",
+				len(problems))
+			return nil
+		}
+
+		subset := dbmap.SubsetDatabase(database, changes.Changed)
+		for _, generator := range cfg.Generators() {
+			if err := runGenerator(generator, cfg, subset); err != nil {
+				fmt.Fprintln(out, err)
+			}
+		}
+		return nil
+	})
+}
+
+func readSource(source string, opts options) (*dbmap.Database, error) {
+	if strings.HasSuffix(source, ".json") {
+		fmt.Fprintln(out, "Using snapshot: ", source)
+		return dbmap.ReadSnapshot(source)
+	}
+
+	cfg, err := loadConfig(source, opts)
+	if err != nil {
+		return nil, err
+	}
+	return readDatabase(cfg)
+}
+
+// initConfig Writes a starter config file, go_dbmap.yml unless another is named, for every schema of the database
+func initConfig(args []string, opts options) error {
+	if len(args) > 1 || opts.dsn == "" {
+		return errUsage
+	}
+	configFile := "go_dbmap.yml"
+	if len(args) == 1 {
+		configFile = args[0]
+	}
+	if _, err := os.Stat(configFile); err == nil {
+		return fmt.Errorf("%s already exists", configFile)
+	}
+
+	cfg := dbmap.Config{Log: out}
+	cfg.Database.Provider = "postgres"
+	cfg.Database.DSN = opts.dsn
+	lister, ok := newProvider(cfg).(dbmap.SchemaLister)
+	if !ok {
+		return fmt.Errorf("the %s provider cannot list the schemas", cfg.Database.Provider)
+	}
+	schemaNames, err := lister.ReadSchemaNames(ctx)
+	if err != nil {
+		return err
+	}
+	if len(schemaNames) == 0 {
+		return errors.New("the database has no tables")
+	}
+	cfg.Generator.Schemas = schemaNames
+
+	database, err := readDatabase(cfg)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(configFile)
+	if err != nil {
+		return err
+	}
+	dbmap.Scaffold(f, cfg, database)
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\nWrote %s for %d schemas, please review it before running go_dbmap generate\n", configFile,
+		len(schemaNames))
+	return nil
+}
+
+func validate(cfg dbmap.Config, opts options) error {
+	fmt.Fprintln(out, "Configuration is valid")
+	return nil
+}
+
 func showUsage() {
-	fmt.Println("Invalid usage!")
-	fmt.Println("usage: go_dbmap <config-file>")
+	fmt.Println("usage: go_dbmap [command] [flags] <config-file>")
+	fmt.Println("       go_dbmap diff [flags] <config-file|snapshot.json> <config-file|snapshot.json>")
+	fmt.Println("       go_dbmap init --dsn <dsn> [<config-file>]")
+	fmt.Println("\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-10s %s\n", name, commands[name].description)
+	}
+
+	fmt.Println("\nThe command defaults to generate. Flags:")
+	fmt.Println("  --schema <name>       Read only this schema instead of generator.schemas (repeatable)")
+	fmt.Println("  --table <pattern>     Read only the matching tables, like included_tables (repeatable)")
+	fmt.Println("  --out <path>          Write the code to this path instead of output.path")
+	fmt.Println("  --proto-out <path>    Write the protos to this path instead of proto.path")
+	fmt.Println("  --json                Print the inspected model, or the changes of diff, as JSON")
+	fmt.Println("  --interval <duration> How often watch reads the database (default 2s)")
+	fmt.Println("  --listen              Have watch LISTEN for schema changes instead of polling (postgres)")
+	fmt.Println("  --install-trigger     Install the event trigger that --listen needs, as a superuser")
+	fmt.Println("  --dsn <dsn>           The database that init writes a config file for")
 	os.Exit(-1)
 }
+
+// stringList A flag that can be repeated or given a comma separated list
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/lib/pq"
+)
+
+// Array Reads and writes the repeated field of an ARRAY column. Pass a pointer to the field, such as &user.Tags, to
+// scan the column, and the field itself as the value of a parameter. The field can be a []string, []int32, []int64,
+// []float32, []float64, []bool or [][]byte. A NULL array is read as a nil slice and a nil slice is written as NULL,
+// while an empty array stays empty. Since a repeated field cannot hold a null, an array with a NULL element cannot
+// be read.
+func Array(a interface{}) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	return pq.Array(a)
+}
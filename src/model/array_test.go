@@ -0,0 +1,42 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArray(t *testing.T) {
+	var tags []string
+	if err := Array(&tags).Scan([]byte(`{go,"db map"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, []string{"go", "db map"}) {
+		t.Errorf("Expected the tags to be read but got %q", tags)
+	}
+	if err := Array(&tags).Scan([]byte(`{go,NULL}`)); err == nil {
+		t.Error("Expected a NULL element to fail")
+	}
+
+	var ids []int32
+	if err := Array(&ids).Scan([]byte(`{1,2,3}`)); err != nil || !reflect.DeepEqual(ids, []int32{1, 2, 3}) {
+		t.Errorf("Expected [1 2 3] but got %v - %v", ids, err)
+	}
+	if err := Array(&ids).Scan([]byte(`{1,2147483648}`)); err == nil {
+		t.Error("Expected an element that does not fit an int32 to fail")
+	}
+	if err := Array(&ids).Scan(nil); err != nil || ids != nil {
+		t.Errorf("Expected NULL to be read as a nil slice but got %v - %v", ids, err)
+	}
+	if err := Array(&ids).Scan([]byte(`{}`)); err != nil || ids == nil || len(ids) != 0 {
+		t.Errorf("Expected an empty array to be read as an empty slice but got %v - %v", ids, err)
+	}
+
+	value, err := Array([]int32{4, 5}).Value()
+	if err != nil || value != "{4,5}" {
+		t.Errorf("Expected {4,5} but got %v - %v", value, err)
+	}
+	value, err = Array([]string(nil)).Value()
+	if err != nil || value != nil {
+		t.Errorf("Expected a nil slice to be written as NULL but got %v - %v", value, err)
+	}
+}
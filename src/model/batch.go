@@ -0,0 +1,59 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MaxParams The most bind parameters that a statement can have, since postgres counts them in a 16 bit integer
+const MaxParams = 65535
+
+// ChunkSize Returns how many rows of paramsPerRow parameters fit in a single multi-row INSERT
+func ChunkSize(paramsPerRow int) int {
+	return MaxParams / paramsPerRow
+}
+
+// Placeholders Returns the placeholders of a row of a multi-row VALUES, where every row has n parameters, so the
+// second row of 8 is $9 to $16. They are returned as the arguments of fmt.Sprintf for the VALUES of the row.
+func Placeholders(row int, n int) []interface{} {
+	placeholders := make([]interface{}, n)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", row*n+i+1)
+	}
+	return placeholders
+}
+
+// Preparer Prepares a statement, which a transaction does
+type Preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// CopyIn Runs the COPY statement of the driver, such as pq.CopyIn, sending the values of each of the rows. The COPY
+// has to be prepared on a transaction, which the generated Copy functions start with WithTx.
+func CopyIn(ctx context.Context, db DBTX, query string, rows int, values func(row int) []interface{}) (err error) {
+	preparer, ok := db.(Preparer)
+	if !ok {
+		return fmt.Errorf("model: cannot copy on a %T", db)
+	}
+
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := stmt.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for row := 0; row < rows; row++ {
+		if _, err := stmt.ExecContext(ctx, values(row)...); err != nil {
+			return err
+		}
+	}
+
+	// Executing without values ends the COPY
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
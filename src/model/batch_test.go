@@ -0,0 +1,43 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	values := fmt.Sprintf("(%s, ST_POINT(%s, %s))", Placeholders(1, 3)...)
+	if values != "($4, ST_POINT($5, $6))" {
+		t.Errorf("Expected the placeholders of the second row but got %s", values)
+	}
+
+	if size := ChunkSize(8); size != 8191 || size*8 > MaxParams {
+		t.Errorf("Expected chunks of 8191 rows but got %d", size)
+	}
+}
+
+func TestCopyIn(t *testing.T) {
+	ctx := context.Background()
+	db := openRecorder(t, 0)
+
+	rows := [][]interface{}{{"Bryan", sql.NullInt32{}}, {"Tom", sql.NullInt32{Int32: 1, Valid: true}}}
+	err := WithTx(ctx, db, nil, func(tx DBTX) error {
+		return CopyIn(ctx, tx, "COPY user", len(rows), func(row int) []interface{} { return rows[row] })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"BEGIN", "COPY user [Bryan <nil>]", "COPY user [Tom 1]", "COPY user []", "COMMIT"}
+	if !reflect.DeepEqual(recorder.log, expected) {
+		t.Errorf("Expected\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(recorder.log, "\n"))
+	}
+
+	if err := CopyIn(ctx, nil, "COPY user", 0, nil); err == nil {
+		t.Error("Expected an error for a handle that cannot prepare")
+	}
+}
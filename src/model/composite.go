@@ -0,0 +1,167 @@
+package model
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Composite Scans and writes the value of a composite (row) type, e.g. (1,"main street",,US). Each attribute is kept
+// as its text representation so that it can be converted using the same Set functions as any other column, where an
+// attribute that is not Valid is NULL.
+type Composite struct {
+	Fields []sql.NullString
+	Valid  bool
+}
+
+func (c *Composite) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		c.Fields, c.Valid = nil, false
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("model: cannot scan %T into a composite", src)
+	}
+
+	fields, err := ParseComposite(s)
+	if err != nil {
+		return err
+	}
+	c.Fields, c.Valid = fields, true
+	return nil
+}
+
+func (c Composite) Value() (driver.Value, error) {
+	if !c.Valid {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, field := range c.Fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if !field.Valid {
+			continue
+		}
+		if field.String != "" && !strings.ContainsAny(field.String, "(),\"\\ \t\n\r") {
+			b.WriteString(field.String)
+			continue
+		}
+		b.WriteByte('"')
+		for _, r := range field.String {
+			if r == '"' || r == '\\' {
+				b.WriteRune(r)
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte(')')
+	return b.String(), nil
+}
+
+// NewComposite Returns the composite of the values of its attributes, in order. Each value is kept as the text that
+// postgres reads it from, and one that is NULL is a NULL attribute.
+func NewComposite(values ...driver.Valuer) (Composite, error) {
+	c := Composite{Fields: make([]sql.NullString, len(values)), Valid: true}
+	for i, valuer := range values {
+		value, err := valuer.Value()
+		if err != nil {
+			return Composite{}, err
+		}
+
+		var s string
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			s = v
+		case []byte:
+			s = `\x` + hex.EncodeToString(v)
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		case float64:
+			s = strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			s = strconv.FormatBool(v)
+		case time.Time:
+			s = v.Format(time.RFC3339Nano)
+		default:
+			return Composite{}, fmt.Errorf("model: cannot write %T as an attribute of a composite", value)
+		}
+		c.Fields[i] = sql.NullString{String: s, Valid: true}
+	}
+	return c, nil
+}
+
+// ScanFields Scans the attributes into dest, in order, where the text of each attribute is converted the same way
+// database/sql converts a column, so that an attribute is read into the nullable type of a column of its type
+func (c Composite) ScanFields(dest ...sql.Scanner) error {
+	if len(dest) != len(c.Fields) {
+		return fmt.Errorf("model: cannot scan a composite of %d attributes into %d fields", len(c.Fields), len(dest))
+	}
+
+	for i, field := range c.Fields {
+		var src interface{}
+		if field.Valid {
+			src = field.String
+		}
+		if err := dest[i].Scan(src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseComposite Parses the text representation of a composite value into its attributes. An empty, unquoted
+// attribute is NULL, while "" is an empty string.
+func ParseComposite(s string) ([]sql.NullString, error) {
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return nil, errors.New("model: malformed composite value " + s)
+	}
+
+	fields := make([]sql.NullString, 0)
+	var field strings.Builder
+	valid := false
+	quoted := false
+	body := s[1 : len(s)-1]
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+		switch {
+		case ch == '\\' && i+1 < len(body):
+			i++
+			field.WriteByte(body[i])
+			valid = true
+		case ch == '"' && quoted && i+1 < len(body) && body[i+1] == '"':
+			i++
+			field.WriteByte('"')
+		case ch == '"':
+			quoted = !quoted
+			valid = true
+		case ch == ',' && !quoted:
+			fields = append(fields, sql.NullString{String: field.String(), Valid: valid})
+			field.Reset()
+			valid = false
+		default:
+			field.WriteByte(ch)
+			valid = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("model: unterminated quote in composite value " + s)
+	}
+	fields = append(fields, sql.NullString{String: field.String(), Valid: valid})
+	return fields, nil
+}
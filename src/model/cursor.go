@@ -0,0 +1,46 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCursor Returned by the generated List functions when the cursor was not returned by them
+var ErrInvalidCursor = errors.New("model: invalid cursor")
+
+// EncodeCursor Returns the opaque cursor of a page, which holds the values of the columns that the list is ordered by
+// for the last row of the page. Callers should treat it as a token that is only handed back to the next call.
+func EncodeCursor(values ...interface{}) (string, error) {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("model: cannot encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor Reads the values of a cursor returned by EncodeCursor into the pointers, which must be of the same
+// number and types as the values that were encoded. An empty cursor is the first page, where nothing is read and false
+// is returned.
+func DecodeCursor(cursor string, values ...interface{}) (bool, error) {
+	if cursor == "" {
+		return false, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return false, ErrInvalidCursor
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil || len(raw) != len(values) {
+		return false, ErrInvalidCursor
+	}
+	for i, value := range values {
+		if err := json.Unmarshal(raw[i], value); err != nil {
+			return false, ErrInvalidCursor
+		}
+	}
+	return true, nil
+}
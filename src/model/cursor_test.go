@@ -0,0 +1,40 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	cursor, err := EncodeCursor(int32(42), "bh@gmail.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var userId int32
+	var email string
+	if after, err := DecodeCursor(cursor, &userId, &email); !after || err != nil {
+		t.Fatalf("Expected the cursor to be decoded but got %t - %v", after, err)
+	}
+	if userId != 42 || email != "bh@gmail.com" {
+		t.Errorf("Expected 42, bh@gmail.com but got %d, %s", userId, email)
+	}
+
+	if after, err := DecodeCursor("", &userId); after || err != nil {
+		t.Errorf("Expected an empty cursor to be the first page but got %t - %v", after, err)
+	}
+
+	one, _ := EncodeCursor(int32(42))
+	text, _ := EncodeCursor("42", "bh@gmail.com")
+	invalid := []string{"not a cursor", one, text, "bnVsbA"}
+	for _, c := range invalid {
+		if _, err := DecodeCursor(c, &userId, &email); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected %q to be invalid but got %v", c, err)
+		}
+	}
+
+	if _, err := EncodeCursor(math.NaN()); err == nil {
+		t.Error("Expected an error for a value that cannot be encoded")
+	}
+}
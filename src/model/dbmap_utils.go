@@ -2,7 +2,6 @@ package model
 
 import (
 	"database/sql"
-	"log"
 	"time"
 )
 
@@ -138,20 +137,19 @@ func SetTime(t sql.NullTime) *time.Time {
 	}
 }
 
+// ReadResults Reads every row as a map of the column names to their values and closes the rows. The error of the query
+// that returned the rows may be passed along, which is then returned as is.
 func ReadResults(rows *sql.Rows, err error) (results []map[string]interface{}, errOut error) {
 	if err != nil {
-		log.Print(err)
 		return nil, err
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Print(err)
-		}
-	}(rows)
+	defer rows.Close()
 
 	results = make([]map[string]interface{}, 0)
-	cols, _ := rows.Columns()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
 	for rows.Next() {
 		columns := make([]interface{}, len(cols))
 		columnPointers := make([]interface{}, len(cols))
@@ -160,12 +158,6 @@ func ReadResults(rows *sql.Rows, err error) (results []map[string]interface{}, e
 		}
 
 		if err := rows.Scan(columnPointers...); err != nil {
-			log.Print(err)
-			return nil, err
-		}
-
-		if err := rows.Err(); err != nil {
-			log.Print(err)
 			return nil, err
 		}
 
@@ -177,5 +169,8 @@ func ReadResults(rows *sql.Rows, err error) (results []map[string]interface{}, e
 		results = append(results, m)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	return results, nil
 }
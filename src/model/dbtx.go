@@ -0,0 +1,14 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX The database handle taken by the generated code. It is satisfied by *sql.DB, *sql.Tx and *sql.Conn, so that the
+// same functions can be run against the pool, inside a transaction or on a dedicated connection.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
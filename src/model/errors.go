@@ -0,0 +1,193 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound Returned by the generated reads, lookups and updates when no row matches. It wraps sql.ErrNoRows, so
+// errors.Is(err, sql.ErrNoRows) is also true
+var ErrNotFound = fmt.Errorf("model: not found: %w", sql.ErrNoRows)
+
+// ErrStaleWrite Returned by the generated updates and deletes of a table with a version column when the row exists but
+// its version is no longer the one that was read, because it was changed since. The row should be read again, and
+// the change applied to it, rather than overwriting the other change.
+var ErrStaleWrite = errors.New("model: stale write, the row was changed since it was read")
+
+// ErrNoVersion Returned by the generated updates and deletes of a table with a version column when the version of the
+// row is not set, so that there is nothing to check it against. The row has to be read, or created, first.
+var ErrNoVersion = errors.New("model: no version, the row has to be read before it is written")
+
+// Error An error of a generated function, naming the table and the operation that failed. The error of the driver is
+// translated to one of the violations below when it is an integrity constraint violation, so that callers can use
+// errors.Is and errors.As rather than matching the messages of the driver.
+type Error struct {
+	Table string
+	Op    string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Table, e.Op, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrUniqueViolation A row with the same key already exists. Columns are the columns of the unique constraint or index
+// when the database reports them.
+type ErrUniqueViolation struct {
+	Constraint string
+	Columns    []string
+	Err        error
+}
+
+func (e *ErrUniqueViolation) Error() string {
+	return fmt.Sprintf("model: unique violation of %s (%s)", e.Constraint, strings.Join(e.Columns, ", "))
+}
+
+func (e *ErrUniqueViolation) Unwrap() error {
+	return e.Err
+}
+
+// ErrForeignKeyViolation A referenced row does not exist, or a row that is still referenced was deleted
+type ErrForeignKeyViolation struct {
+	Constraint string
+	Err        error
+}
+
+func (e *ErrForeignKeyViolation) Error() string {
+	return fmt.Sprintf("model: foreign key violation of %s", e.Constraint)
+}
+
+func (e *ErrForeignKeyViolation) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotNullViolation A column that is defined as not null was given a null value. Err is nil when the generated code
+// caught it before the statement was run.
+type ErrNotNullViolation struct {
+	Column string
+	Err    error
+}
+
+func (e *ErrNotNullViolation) Error() string {
+	return fmt.Sprintf("model: %s is defined as not null but has a null value", e.Column)
+}
+
+func (e *ErrNotNullViolation) Unwrap() error {
+	return e.Err
+}
+
+// The SQLSTATE codes of the integrity constraint violations
+const (
+	sqlStateNotNullViolation    = "23502"
+	sqlStateForeignKeyViolation = "23503"
+	sqlStateUniqueViolation     = "23505"
+)
+
+// The error numbers of the integrity constraint violations of MySQL and MariaDB, which report them all with the
+// SQLSTATE 23000
+const (
+	mysqlDupEntry        = 1062
+	mysqlBadNull         = 1048
+	mysqlRowIsReferenced = 1451
+	mysqlNoReferencedRow = 1452
+)
+
+// Matches the key of a unique violation in the detail reported by PostgreSQL, e.g. Key (email)=(bh@gmail.com) already
+// exists.
+var uniqueKey = regexp.MustCompile(`^Key \((.+?)\)=`)
+
+// Matches the message of an error of github.com/go-sql-driver/mysql, e.g. Error 1062 (23000): Duplicate entry ..., by
+// which the error number and the text of the server are read without depending on that driver. Older versions of the
+// driver leave out the SQLSTATE.
+var mysqlMessage = regexp.MustCompile(`^Error (\d+)(?: \([0-9A-Z]{5}\))?: (.*)$`)
+
+// Match the constraint or column in the messages of MySQL and MariaDB, e.g. Duplicate entry 'bh@gmail.com' for key
+// 'user.lookup_email', ... CONSTRAINT `fk_user_user` FOREIGN KEY ... and Column 'email' cannot be null
+var (
+	mysqlDupKey     = regexp.MustCompile(`for key '(?:[^']*\.)?([^'.]+)'$`)
+	mysqlForeignKey = regexp.MustCompile("CONSTRAINT `([^`]+)` FOREIGN KEY")
+	mysqlColumn     = regexp.MustCompile(`^Column '([^']+)' cannot be null`)
+)
+
+// WrapError Returns the error of the driver as an *Error of the table and operation, or nil when err is nil. An
+// integrity constraint violation is translated to an ErrUniqueViolation, ErrForeignKeyViolation or ErrNotNullViolation,
+// which wraps the error of the driver.
+func WrapError(table string, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Table: table, Op: op, Err: translateError(err)}
+}
+
+// translateError Maps the SQLSTATE of a postgres error, or the error number of a MySQL or MariaDB error, to a
+// violation. The constraint, column and detail of a postgres error are read through the Get method of *pq.Error, so
+// that the model does not depend on that driver, while MySQL and MariaDB only report them in the message.
+func translateError(err error) error {
+	if violation := translateMySQLError(err); violation != nil {
+		return violation
+	}
+
+	state := SQLState(err)
+	if state == "" {
+		return err
+	}
+
+	field := func(code byte) string {
+		var pgErr interface{ Get(k byte) string }
+		if errors.As(err, &pgErr) {
+			return pgErr.Get(code)
+		}
+		return ""
+	}
+
+	switch state {
+	case sqlStateUniqueViolation:
+		var columns []string
+		if m := uniqueKey.FindStringSubmatch(field('D')); m != nil {
+			columns = strings.Split(m[1], ", ")
+		}
+		return &ErrUniqueViolation{Constraint: field('n'), Columns: columns, Err: err}
+	case sqlStateForeignKeyViolation:
+		return &ErrForeignKeyViolation{Constraint: field('n'), Err: err}
+	case sqlStateNotNullViolation:
+		return &ErrNotNullViolation{Column: field('c'), Err: err}
+	}
+	return err
+}
+
+// translateMySQLError Returns the violation of the first MySQL or MariaDB error in the chain of err, which wraps err, or
+// nil when there is none. A duplicate entry does not name the columns of the key.
+func translateMySQLError(err error) error {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		m := mysqlMessage.FindStringSubmatch(e.Error())
+		if m == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(m[1])
+		match := func(re *regexp.Regexp) string {
+			if found := re.FindStringSubmatch(m[2]); found != nil {
+				return found[1]
+			}
+			return ""
+		}
+
+		switch number {
+		case mysqlDupEntry:
+			return &ErrUniqueViolation{Constraint: match(mysqlDupKey), Err: err}
+		case mysqlRowIsReferenced, mysqlNoReferencedRow:
+			return &ErrForeignKeyViolation{Constraint: match(mysqlForeignKey), Err: err}
+		case mysqlBadNull:
+			return &ErrNotNullViolation{Column: match(mysqlColumn), Err: err}
+		}
+		return nil
+	}
+	return nil
+}
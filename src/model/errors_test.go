@@ -0,0 +1,120 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"reflect"
+	"testing"
+)
+
+func TestWrapError(t *testing.T) {
+	if WrapError("test_schema.user", "create", nil) != nil {
+		t.Error("Expected nil to stay nil")
+	}
+
+	unique := &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint \"lookup_email\"",
+		Detail: "Key (email)=(bh@gmail.com) already exists.", Constraint: "lookup_email"}
+	err := WrapError("test_schema.user", "create", unique)
+	var uniqueViolation *ErrUniqueViolation
+	if !errors.As(err, &uniqueViolation) || uniqueViolation.Constraint != "lookup_email" ||
+		!reflect.DeepEqual(uniqueViolation.Columns, []string{"email"}) {
+		t.Errorf("Expected a unique violation of lookup_email (email) but got %v", err)
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr != unique {
+		t.Error("Expected the error of the driver to be wrapped")
+	}
+	if err.Error() != "test_schema.user: create: model: unique violation of lookup_email (email)" {
+		t.Errorf("Unexpected message %s", err)
+	}
+
+	composite := &pq.Error{Code: "23505", Detail: "Key (user_id, product_id)=(1, 2) already exists.",
+		Constraint: "unq_user_product"}
+	if !errors.As(WrapError("t", "create", composite), &uniqueViolation) ||
+		!reflect.DeepEqual(uniqueViolation.Columns, []string{"user_id", "product_id"}) {
+		t.Errorf("Expected the columns of the composite key but got %v", uniqueViolation.Columns)
+	}
+
+	var fkViolation *ErrForeignKeyViolation
+	err = WrapError("test_schema.user", "update", &pq.Error{Code: "23503", Constraint: "fk_user_user"})
+	if !errors.As(err, &fkViolation) || fkViolation.Constraint != "fk_user_user" {
+		t.Errorf("Expected a foreign key violation of fk_user_user but got %v", err)
+	}
+
+	var notNullViolation *ErrNotNullViolation
+	err = WrapError("test_schema.user", "create", &pq.Error{Code: "23502", Column: "email"})
+	if !errors.As(err, &notNullViolation) || notNullViolation.Column != "email" {
+		t.Errorf("Expected a not null violation of email but got %v", err)
+	}
+
+	// MySQL and MariaDB only report the constraint or column in the message
+	mysqlCases := []struct {
+		err      error
+		expected error
+	}{
+		{mysqlError{1062, "23000", "Duplicate entry 'bh@gmail.com' for key 'user.lookup_email'"},
+			&ErrUniqueViolation{Constraint: "lookup_email"}},
+		{mysqlError{1062, "", "Duplicate entry 'bh@gmail.com' for key 'lookup_email'"},
+			&ErrUniqueViolation{Constraint: "lookup_email"}},
+		{mysqlError{1452, "23000", "Cannot add or update a child row: a foreign key constraint fails " +
+			"(`test`.`user`, CONSTRAINT `fk_user_user` FOREIGN KEY (`aka_id`) REFERENCES `user` (`user_id`))"},
+			&ErrForeignKeyViolation{Constraint: "fk_user_user"}},
+		{mysqlError{1451, "23000", "Cannot delete or update a parent row: a foreign key constraint fails " +
+			"(`test`.`user`, CONSTRAINT `fk_user_user` FOREIGN KEY (`aka_id`) REFERENCES `user` (`user_id`))"},
+			&ErrForeignKeyViolation{Constraint: "fk_user_user"}},
+		{mysqlError{1048, "23000", "Column 'email' cannot be null"}, &ErrNotNullViolation{Column: "email"}},
+		{fmt.Errorf("exec: %w", mysqlError{1048, "23000", "Column 'email' cannot be null"}),
+			&ErrNotNullViolation{Column: "email"}},
+	}
+	for _, c := range mysqlCases {
+		err = WrapError("test_schema.user", "create", c.err)
+		if err.Error() != "test_schema.user: create: "+c.expected.Error() {
+			t.Errorf("Expected %v for %v but got %v", c.expected, c.err, err)
+		}
+		if !errors.Is(err, c.err) {
+			t.Errorf("Expected the error of the driver to be wrapped for %v", c.err)
+		}
+	}
+	if !errors.As(WrapError("t", "create", mysqlCases[0].err), &uniqueViolation) {
+		t.Error("Expected errors.As to find the unique violation of MySQL")
+	}
+	deadlock := mysqlError{1213, "40001", "Deadlock found when trying to get lock"}
+	if err = WrapError("t", "update", deadlock); errors.As(err, &uniqueViolation) || !errors.Is(err, deadlock) {
+		t.Errorf("Expected an error that is not a violation to stay as it is but got %v", err)
+	}
+
+	other := errors.New("connection refused")
+	err = WrapError("test_schema.user", "read", other)
+	var wrapped *Error
+	if !errors.As(err, &wrapped) || wrapped.Table != "test_schema.user" || wrapped.Op != "read" ||
+		!errors.Is(err, other) {
+		t.Errorf("Expected the error to be wrapped with the table and operation but got %v", err)
+	}
+}
+
+func TestErrNotFound(t *testing.T) {
+	err := WrapError("test_schema.user", "read", ErrNotFound)
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected %v to be ErrNotFound and sql.ErrNoRows", err)
+	}
+	if err.Error() != "test_schema.user: read: model: not found: sql: no rows in result set" {
+		t.Errorf("Unexpected message %s", err)
+	}
+}
+
+// mysqlError An error with the message of the errors of github.com/go-sql-driver/mysql, which leaves out the SQLSTATE
+// when the server does not report one
+type mysqlError struct {
+	number  int
+	state   string
+	message string
+}
+
+func (e mysqlError) Error() string {
+	if e.state == "" {
+		return fmt.Sprintf("Error %d: %s", e.number, e.message)
+	}
+	return fmt.Sprintf("Error %d (%s): %s", e.number, e.state, e.message)
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StringFilter The conditions on a text column of a generated filter, where every condition that is set must hold.
+// Like is a pattern of the LIKE operator, so % and _ are wildcards. An In that is empty but not nil matches no row.
+type StringFilter struct {
+	Eq     *string
+	In     []string
+	Like   *string
+	IsNull *bool
+}
+
+// Int32Filter The conditions on an integer column of a generated filter, where every condition that is set must hold.
+// Gt, Gte, Lt and Lte bound a range. An In that is empty but not nil matches no row.
+type Int32Filter struct {
+	Eq     *int32
+	In     []int32
+	Gt     *int32
+	Gte    *int32
+	Lt     *int32
+	Lte    *int32
+	IsNull *bool
+}
+
+// Sort A column to sort by, which must be one of the sortable columns of the table
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// ErrInvalidSort Returned by the generated Find functions when a column that is not sortable is sorted by
+var ErrInvalidSort = errors.New("model: invalid sort")
+
+// Where Builds the WHERE clause of a query from the filters, passing every value as a parameter so that nothing the
+// caller gives becomes part of the SQL. The columns are always the names written by the generator.
+type Where struct {
+	conditions []string
+	args       []interface{}
+}
+
+// Param Adds the value as the next parameter of the query and returns its placeholder
+func (w *Where) Param(value interface{}) string {
+	w.args = append(w.args, value)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+// String Adds the conditions of the filter on the column, if the filter is set
+func (w *Where) String(column string, f *StringFilter) {
+	if f == nil {
+		return
+	}
+	if f.Eq != nil {
+		w.conditions = append(w.conditions, column+" = "+w.Param(*f.Eq))
+	}
+	if f.In != nil {
+		values := make([]interface{}, len(f.In))
+		for i, v := range f.In {
+			values[i] = v
+		}
+		w.in(column, values)
+	}
+	if f.Like != nil {
+		w.conditions = append(w.conditions, column+" LIKE "+w.Param(*f.Like))
+	}
+	w.isNull(column, f.IsNull)
+}
+
+// Int32 Adds the conditions of the filter on the column, if the filter is set
+func (w *Where) Int32(column string, f *Int32Filter) {
+	if f == nil {
+		return
+	}
+	if f.Eq != nil {
+		w.conditions = append(w.conditions, column+" = "+w.Param(*f.Eq))
+	}
+	if f.In != nil {
+		values := make([]interface{}, len(f.In))
+		for i, v := range f.In {
+			values[i] = v
+		}
+		w.in(column, values)
+	}
+	bounds := []struct {
+		op    string
+		value *int32
+	}{{" > ", f.Gt}, {" >= ", f.Gte}, {" < ", f.Lt}, {" <= ", f.Lte}}
+	for _, bound := range bounds {
+		if bound.value != nil {
+			w.conditions = append(w.conditions, column+bound.op+w.Param(*bound.value))
+		}
+	}
+	w.isNull(column, f.IsNull)
+}
+
+// Bool Adds the condition that the column equals the value, if the value is set
+func (w *Where) Bool(column string, value *bool) {
+	if value != nil {
+		w.conditions = append(w.conditions, column+" = "+w.Param(*value))
+	}
+}
+
+func (w *Where) in(column string, values []interface{}) {
+	if len(values) == 0 {
+		w.conditions = append(w.conditions, "false")
+		return
+	}
+	params := make([]string, len(values))
+	for i, v := range values {
+		params[i] = w.Param(v)
+	}
+	w.conditions = append(w.conditions, column+" IN ("+strings.Join(params, ", ")+")")
+}
+
+func (w *Where) isNull(column string, isNull *bool) {
+	if isNull == nil {
+		return
+	}
+	if *isNull {
+		w.conditions = append(w.conditions, column+" IS NULL")
+	} else {
+		w.conditions = append(w.conditions, column+" IS NOT NULL")
+	}
+}
+
+// SQL Returns the WHERE clause, with a leading space, or an empty string when no condition was added
+func (w *Where) SQL() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conditions, " AND ")
+}
+
+// Args Returns the values of the parameters in the order of their placeholders
+func (w *Where) Args() []interface{} {
+	return w.args
+}
+
+// OrderBy Returns the ORDER BY clause of the sort, with a leading space. Only the sortable columns, which the generator
+// writes, are accepted. The key columns are sorted by last so that rows which are equal on the sort come back in the
+// same order on every page.
+func OrderBy(sort []Sort, sortable []string, key ...string) (string, error) {
+	allowed := make(map[string]bool, len(sortable))
+	for _, column := range sortable {
+		allowed[column] = true
+	}
+
+	sorted := make(map[string]bool)
+	terms := make([]string, 0, len(sort)+len(key))
+	for _, s := range sort {
+		if !allowed[s.Column] {
+			return "", fmt.Errorf("%w: %s is not sortable", ErrInvalidSort, s.Column)
+		}
+		if sorted[s.Column] {
+			continue
+		}
+		sorted[s.Column] = true
+		if s.Desc {
+			terms = append(terms, s.Column+" DESC")
+		} else {
+			terms = append(terms, s.Column)
+		}
+	}
+	for _, column := range key {
+		if !sorted[column] {
+			terms = append(terms, column)
+		}
+	}
+
+	if len(terms) == 0 {
+		return "", nil
+	}
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
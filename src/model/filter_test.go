@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWhere(t *testing.T) {
+	email := "%@gmail.com"
+	min, max := int32(10), int32(20)
+	isNull, enabled := true, false
+
+	var where Where
+	where.String("email", &StringFilter{Like: &email, In: []string{"a@gmail.com", "b@gmail.com"}})
+	where.Int32("user_id", &Int32Filter{Gte: &min, Lt: &max})
+	where.Int32("aka_id", &Int32Filter{IsNull: &isNull})
+	where.Bool("enabled", &enabled)
+	where.String("last_name", nil)
+	where.Bool("deleted", nil)
+
+	expected := " WHERE email IN ($1, $2) AND email LIKE $3 AND user_id >= $4 AND user_id < $5 AND aka_id IS NULL AND " +
+		"enabled = $6"
+	if where.SQL() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, where.SQL())
+	}
+	args := []interface{}{"a@gmail.com", "b@gmail.com", email, min, max, enabled}
+	if !reflect.DeepEqual(where.Args(), args) {
+		t.Errorf("Expected the parameters %v but got %v", args, where.Args())
+	}
+	if where.Param(int32(100)) != "$7" {
+		t.Error("Expected the next parameter to be $7")
+	}
+
+	var none Where
+	none.Int32("user_id", &Int32Filter{})
+	if none.SQL() != "" || len(none.Args()) != 0 {
+		t.Errorf("Expected an empty filter to have no condition but got %s", none.SQL())
+	}
+	none.Int32("user_id", &Int32Filter{In: []int32{}})
+	if none.SQL() != " WHERE false" {
+		t.Errorf("Expected an empty In to match nothing but got %s", none.SQL())
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	sortable := []string{"user_id", "email", "last_name"}
+
+	orderBy, err := OrderBy([]Sort{{Column: "last_name", Desc: true}, {Column: "email"}}, sortable, "user_id")
+	if err != nil || orderBy != " ORDER BY last_name DESC, email, user_id" {
+		t.Errorf("Unexpected order %s - %v", orderBy, err)
+	}
+
+	orderBy, err = OrderBy([]Sort{{Column: "user_id", Desc: true}}, sortable, "user_id")
+	if err != nil || orderBy != " ORDER BY user_id DESC" {
+		t.Errorf("Expected the key not to be sorted by twice but got %s - %v", orderBy, err)
+	}
+
+	if _, err := OrderBy([]Sort{{Column: "email; DROP TABLE user"}}, sortable, "user_id"); !errors.Is(err,
+		ErrInvalidSort) {
+		t.Errorf("Expected ErrInvalidSort but got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidMask Returned by the generated UpdateMask functions when a path of the field mask is not a field that can
+// be updated, or when only some of the fields that a transform reads are given
+var ErrInvalidMask = errors.New("model: invalid field mask")
+
+// MaskColumn A column that the generated UpdateMask functions can set. Value is the SQL of the new value with a %s for
+// the parameter of each of the Paths, so a plain column is "%s" and a column written by a transform, such as a
+// geography from a lat and lon, is "ST_POINT(%s, %s)::geography" with the paths lon and lat.
+type MaskColumn struct {
+	Column string
+	Value  string
+	Paths  []string
+}
+
+// MaskSet Returns the assignments of the SET of an UPDATE for the paths of a field mask, with the parameters starting
+// after offset, and the paths whose values are the parameters in order. A column is set when the mask has all of its
+// paths. It is an error for a path not to be one of the columns, or for the mask to have only some of the paths of a
+// column.
+func MaskSet(paths []string, columns []MaskColumn, offset int) (set string, fields []string, err error) {
+	masked := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		masked[path] = true
+	}
+
+	known := make(map[string]bool)
+	assignments := make([]string, 0)
+	for _, column := range columns {
+		count := 0
+		for _, path := range column.Paths {
+			known[path] = true
+			if masked[path] {
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		} else if count < len(column.Paths) {
+			return "", nil, fmt.Errorf("%w: %s must be updated together", ErrInvalidMask,
+				strings.Join(column.Paths, " and "))
+		}
+
+		params := make([]interface{}, len(column.Paths))
+		for i, path := range column.Paths {
+			fields = append(fields, path)
+			params[i] = fmt.Sprintf("$%d", offset+len(fields))
+		}
+		assignments = append(assignments, column.Column+" = "+fmt.Sprintf(column.Value, params...))
+	}
+
+	for _, path := range paths {
+		if !known[path] {
+			return "", nil, fmt.Errorf("%w: %s is not a field that can be updated", ErrInvalidMask, path)
+		}
+	}
+	return strings.Join(assignments, ", "), fields, nil
+}
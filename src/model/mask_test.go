@@ -0,0 +1,30 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMaskSet(t *testing.T) {
+	columns := []MaskColumn{
+		{Column: "first_name", Value: "%s", Paths: []string{"first_name"}},
+		{Column: "email", Value: "%s", Paths: []string{"email"}},
+		{Column: "geog", Value: "ST_POINT(%s, %s)::geography", Paths: []string{"lon", "lat"}},
+	}
+
+	set, fields, err := MaskSet([]string{"lat", "first_name", "lon"}, columns, 1)
+	if err != nil || set != "first_name = $2, geog = ST_POINT($3, $4)::geography" {
+		t.Errorf("Unexpected set %s - %v", set, err)
+	}
+	if !reflect.DeepEqual(fields, []string{"first_name", "lon", "lat"}) {
+		t.Errorf("Expected the fields in the order of the parameters but got %v", fields)
+	}
+
+	if _, _, err := MaskSet([]string{"email", "lat"}, columns, 1); !errors.Is(err, ErrInvalidMask) {
+		t.Errorf("Expected lat without lon to be invalid but got %v", err)
+	}
+	if _, _, err := MaskSet([]string{"user_id"}, columns, 1); !errors.Is(err, ErrInvalidMask) {
+		t.Errorf("Expected a field that cannot be updated to be invalid but got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxPageSize The most rows that a page of the generated List and Find functions holds. A larger limit is lowered to
+// it, so that a single call cannot read a whole large table into memory
+const MaxPageSize = 1000
+
+// ErrInvalidLimit Returned by the generated List and Find functions when the limit of a page is not positive
+var ErrInvalidLimit = errors.New("model: invalid limit")
+
+// PageSize Returns the number of rows of a page of at most limit rows, which is MaxPageSize when the limit is larger.
+// A limit that is zero or negative returns ErrInvalidLimit.
+func PageSize(limit int32) (int32, error) {
+	if limit <= 0 {
+		return 0, fmt.Errorf("%w: %d must be positive", ErrInvalidLimit, limit)
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize, nil
+	}
+	return limit, nil
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPageSize(t *testing.T) {
+	cases := []struct {
+		limit    int32
+		expected int32
+	}{{1, 1}, {100, 100}, {MaxPageSize, MaxPageSize}, {MaxPageSize + 1, MaxPageSize}, {math.MaxInt32, MaxPageSize}}
+	for _, c := range cases {
+		if size, err := PageSize(c.limit); size != c.expected || err != nil {
+			t.Errorf("Expected %d for a limit of %d but got %d - %v", c.expected, c.limit, size, err)
+		}
+	}
+
+	for _, limit := range []int32{0, -1, math.MinInt32} {
+		if _, err := PageSize(limit); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("Expected ErrInvalidLimit for a limit of %d but got %v", limit, err)
+		}
+	}
+}
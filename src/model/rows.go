@@ -0,0 +1,32 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// NoRow Returns the error that ended the rows, or ErrNotFound when there simply was no row
+func NoRow(rows *sql.Rows) error {
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return ErrNotFound
+}
+
+// StaleOrNoRow Returns the error of NoRow for a write by key and version that matched no row, except that it is
+// ErrStaleWrite when the row exists, since then it was its version that did not match. The exists query selects
+// whether the row with the keys exists.
+func StaleOrNoRow(ctx context.Context, db DBTX, rows *sql.Rows, exists string, keys ...interface{}) error {
+	if err := NoRow(rows); !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	var found bool
+	if err := db.QueryRowContext(ctx, exists, keys...).Scan(&found); err != nil {
+		return err
+	} else if found {
+		return ErrStaleWrite
+	}
+	return ErrNotFound
+}
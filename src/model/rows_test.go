@@ -0,0 +1,118 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// A driver whose queries return no rows, except for "exists", which returns whether its key is 1, and "broken", whose
+// rows end with an error
+type rowsDriver struct{}
+
+var errBroken = errors.New("connection reset")
+
+func (rowsDriver) Open(name string) (driver.Conn, error) { return rowsConn{}, nil }
+
+type rowsConn struct{}
+
+func (rowsConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (rowsConn) Close() error                              { return nil }
+func (rowsConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+func (rowsConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch query {
+	case "exists":
+		return &boolRows{values: []bool{args[0].Value == int64(1)}}, nil
+	case "broken":
+		return &boolRows{err: errBroken}, nil
+	}
+	return &boolRows{}, nil
+}
+
+type boolRows struct {
+	values []bool
+	err    error
+}
+
+func (r *boolRows) Columns() []string { return []string{"exists"} }
+func (r *boolRows) Close() error      { return nil }
+func (r *boolRows) Next(dest []driver.Value) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+var registerRows sync.Once
+
+func openRows(t *testing.T) *sql.DB {
+	registerRows.Do(func() { sql.Register("model_rows", rowsDriver{}) })
+	db, err := sql.Open("model_rows", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestNoRow(t *testing.T) {
+	db := openRows(t)
+	defer func() { _ = db.Close() }()
+
+	cases := []struct {
+		query    string
+		expected error
+	}{
+		{"read", ErrNotFound},
+		{"broken", errBroken},
+	}
+	for i, c := range cases {
+		rows, err := db.QueryContext(context.Background(), c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows.Next() {
+			t.Fatalf("%d) Expected no row", i)
+		}
+		if err := NoRow(rows); !errors.Is(err, c.expected) {
+			t.Errorf("%d) Expected %v but got %v", i, c.expected, err)
+		}
+		_ = rows.Close()
+	}
+}
+
+func TestStaleOrNoRow(t *testing.T) {
+	db := openRows(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	cases := []struct {
+		query    string
+		key      int64
+		expected error
+	}{
+		{"update", 1, ErrStaleWrite},
+		{"update", 2, ErrNotFound},
+		{"broken", 1, errBroken},
+	}
+	for i, c := range cases {
+		rows, err := db.QueryContext(ctx, c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows.Next() {
+			t.Fatalf("%d) Expected no row", i)
+		}
+		if err := StaleOrNoRow(ctx, db, rows, "exists", c.key); !errors.Is(err, c.expected) {
+			t.Errorf("%d) Expected %v but got %v", i, c.expected, err)
+		}
+		_ = rows.Close()
+	}
+}
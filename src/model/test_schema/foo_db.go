@@ -1,8 +1,11 @@
 package test_schema
 
-import "database/sql"
+import (
+	"context"
+	"github.com/bryanhughes/go_dbmap/src/model"
+)
 
-func (m *Foo) Create(db *sql.DB) (err error) {
+func (m *Foo) Create(ctx context.Context, db model.DBTX) (err error) {
 	// nullable := toNullableUser(m)
 	// rows, err := _db.Query(insertStr, nullable.firstName, nullable.lastName, nullable.email, nullable.userToken, nullable.enabled, nullable.akaId, nullable.lon, nullable.lat)
 	// if err != nil {
@@ -28,8 +28,10 @@ type User struct {
 	UserToken            *string  `protobuf:"bytes,5,opt,name=user_token,json=userToken" json:"user_token,omitempty"`
 	Enabled              *bool    `protobuf:"varint,6,opt,name=enabled" json:"enabled,omitempty"`
 	AkaId                *int32   `protobuf:"varint,7,opt,name=aka_id,json=akaId" json:"aka_id,omitempty"`
-	Lat                  *float64 `protobuf:"fixed64,8,opt,name=lat" json:"lat,omitempty"`
-	Lon                  *float64 `protobuf:"fixed64,9,opt,name=lon" json:"lon,omitempty"`
+	Version              *int32   `protobuf:"varint,8,opt,name=version" json:"version,omitempty"`
+	Tags                 []string `protobuf:"bytes,9,rep,name=tags" json:"tags,omitempty"`
+	Lat                  *float64 `protobuf:"fixed64,10,opt,name=lat" json:"lat,omitempty"`
+	Lon                  *float64 `protobuf:"fixed64,11,opt,name=lon" json:"lon,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -109,6 +111,20 @@ func (m *User) GetAkaId() int32 {
 	return 0
 }
 
+func (m *User) GetVersion() int32 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+func (m *User) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
 func (m *User) GetLat() float64 {
 	if m != nil && m.Lat != nil {
 		return *m.Lat
@@ -130,21 +146,22 @@ func init() {
 func init() { proto.RegisterFile("test_schema/user.proto", fileDescriptor_43feef3e3d9881c0) }
 
 var fileDescriptor_43feef3e3d9881c0 = []byte{
-	// 243 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x4c, 0x90, 0xcf, 0x4a, 0x03, 0x31,
-	0x10, 0x87, 0x49, 0xb7, 0xfb, 0x27, 0xe3, 0x45, 0x82, 0xda, 0x80, 0x08, 0xe2, 0xc9, 0xd3, 0xf6,
-	0x1d, 0x8a, 0x1e, 0x3c, 0x28, 0x65, 0xd1, 0x73, 0x19, 0xbb, 0x23, 0x2e, 0xcd, 0x26, 0x65, 0x37,
-	0x82, 0xcf, 0xe3, 0xbb, 0xf9, 0x0e, 0x1e, 0x9d, 0x49, 0x5b, 0xd8, 0x5b, 0x7e, 0xdf, 0x97, 0xcc,
-	0x4c, 0x06, 0xae, 0x22, 0x8d, 0x71, 0x33, 0x6e, 0x3f, 0xa9, 0xc7, 0xe5, 0xd7, 0x48, 0x43, 0xbd,
-	0x1f, 0x42, 0x0c, 0xe6, 0x6c, 0xc2, 0xef, 0x7e, 0x15, 0xcc, 0xdf, 0xd8, 0x99, 0x05, 0x94, 0x72,
-	0x67, 0xd3, 0xb5, 0x56, 0xdd, 0xaa, 0xfb, 0xbc, 0x29, 0x24, 0x3e, 0xb5, 0xe6, 0x06, 0xe0, 0xa3,
-	0x1b, 0xf8, 0x85, 0xc7, 0x9e, 0xec, 0x8c, 0x9d, 0x6e, 0x74, 0x22, 0x2f, 0x0c, 0xcc, 0x35, 0x68,
-	0x87, 0x27, 0x9b, 0x25, 0x5b, 0x09, 0x48, 0xf2, 0x02, 0x72, 0x6e, 0xd2, 0x39, 0x3b, 0x4f, 0xe2,
-	0x10, 0xa4, 0x62, 0x6a, 0x15, 0xc3, 0x8e, 0xbc, 0xcd, 0x0f, 0x15, 0x85, 0xbc, 0x0a, 0x30, 0x16,
-	0x4a, 0xf2, 0xf8, 0xee, 0xa8, 0xb5, 0x05, 0xbb, 0xaa, 0x39, 0x45, 0x73, 0x09, 0x05, 0xee, 0x50,
-	0x46, 0x2c, 0xd3, 0x88, 0x39, 0x27, 0x9e, 0xf0, 0x1c, 0x32, 0x87, 0xd1, 0x56, 0xcc, 0x54, 0x23,
-	0xc7, 0x44, 0x82, 0xb7, 0xfa, 0x48, 0x82, 0x5f, 0x2d, 0x61, 0xb1, 0x0d, 0x7d, 0x4d, 0xdf, 0xd8,
-	0xef, 0x1d, 0xd5, 0x93, 0x15, 0xac, 0xb4, 0xfc, 0x7f, 0x2d, 0xab, 0x59, 0xab, 0x3f, 0xa5, 0x7e,
-	0x66, 0xd9, 0xe3, 0xc3, 0xf3, 0x7f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xd6, 0x2c, 0xc5, 0xb0, 0x3e,
-	0x01, 0x00, 0x00,
+	// 266 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x90, 0xcd, 0x4a, 0xc3, 0x40,
+	0x14, 0x85, 0x99, 0xe6, 0x77, 0x6e, 0x37, 0x32, 0xa8, 0x1d, 0x10, 0x21, 0xb8, 0xca, 0x2a, 0x7d,
+	0x87, 0xa2, 0x8b, 0x2e, 0x94, 0x12, 0x74, 0x1d, 0xae, 0xcd, 0x55, 0x43, 0xe6, 0xa7, 0x64, 0x46,
+	0xf1, 0x3d, 0x7c, 0x03, 0x9f, 0xd2, 0xa5, 0xcc, 0xb4, 0x81, 0xee, 0xee, 0xf9, 0xce, 0xcc, 0xbd,
+	0x87, 0x03, 0xd7, 0x9e, 0x9c, 0xef, 0xdc, 0xfe, 0x83, 0x34, 0xae, 0x3f, 0x1d, 0x4d, 0xcd, 0x61,
+	0xb2, 0xde, 0x8a, 0xe5, 0x19, 0xbf, 0xfb, 0x59, 0x40, 0xfa, 0xe2, 0x68, 0x12, 0x2b, 0x28, 0xc2,
+	0x9b, 0x6e, 0xe8, 0x25, 0xab, 0x58, 0x9d, 0xb5, 0x79, 0x90, 0xdb, 0x5e, 0xdc, 0x02, 0xbc, 0x0d,
+	0x93, 0xf3, 0x9d, 0x41, 0x4d, 0x72, 0x51, 0xb1, 0x9a, 0xb7, 0x3c, 0x92, 0x27, 0xd4, 0x24, 0x6e,
+	0x80, 0x2b, 0x9c, 0xdd, 0x24, 0xba, 0xa5, 0xc2, 0x93, 0x79, 0x09, 0x19, 0x69, 0x1c, 0x94, 0x4c,
+	0xa3, 0x71, 0x14, 0x61, 0x63, 0x3c, 0xe5, 0xed, 0x48, 0x46, 0x66, 0xc7, 0x8d, 0x81, 0x3c, 0x07,
+	0x20, 0x24, 0x14, 0x64, 0xf0, 0x55, 0x51, 0x2f, 0xf3, 0x8a, 0xd5, 0x65, 0x3b, 0x4b, 0x71, 0x05,
+	0x39, 0x8e, 0x18, 0x22, 0x16, 0x31, 0x62, 0x86, 0x23, 0x6e, 0xfb, 0xf0, 0xe1, 0x8b, 0x26, 0x37,
+	0x58, 0x23, 0xcb, 0xc8, 0x67, 0x29, 0x04, 0xa4, 0x1e, 0xdf, 0x9d, 0xe4, 0x55, 0x52, 0xf3, 0x36,
+	0xce, 0xe2, 0x02, 0x12, 0x85, 0x5e, 0x42, 0xc5, 0x6a, 0xd6, 0x86, 0x31, 0x12, 0x6b, 0xe4, 0xf2,
+	0x44, 0xac, 0xd9, 0xac, 0x61, 0xb5, 0xb7, 0xba, 0xa1, 0x6f, 0xd4, 0x07, 0x45, 0xcd, 0x59, 0x61,
+	0x1b, 0x1e, 0xda, 0xda, 0x85, 0x22, 0x77, 0xec, 0x8f, 0xb1, 0xdf, 0x45, 0xf2, 0x70, 0xff, 0xf8,
+	0x3f, 0x00, 0x91, 0x38, 0x38, 0x54, 0x6c, 0x01, 0x00, 0x00,
 }
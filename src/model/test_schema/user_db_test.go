@@ -1,21 +1,32 @@
 package test_schema
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"github.com/bryanhughes/go_dbmap/src/dbmap"
+	"github.com/bryanhughes/go_dbmap/src/model"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"io"
 	"log"
+	"math"
 	"os"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
 
 var db *sql.DB = nil
 
+var ctx = context.Background()
+
 var enabled = true
 
 func newUUID() uuid.UUID {
@@ -34,16 +45,14 @@ func setupTestCase(t *testing.T) func(t *testing.T) {
 
 	if db == nil {
 		var cfg dbmap.Config
-		dbmap.ReadFile(&cfg, configFile)
-
-		t.Logf("Connecting to %s://user=%s:%s/%s\n", cfg.Database.Provider, cfg.Database.Username, cfg.Database.Host, cfg.Database.Database)
+		if err := dbmap.ReadFile(&cfg, configFile); err != nil {
+			t.Fatal(err)
+		}
 
-		dataSource := "host=" + cfg.Database.Host + " port=" + cfg.Database.Port +
-			" user=" + cfg.Database.Username + " password=" + cfg.Database.Password +
-			" dbname=" + cfg.Database.Database + " sslmode=disable"
+		t.Logf("Connecting to %s: %s\n", cfg.Database.Provider, cfg.RedactedDataSource())
 
 		var err error
-		db, err = sql.Open("postgres", dataSource)
+		db, err = sql.Open("postgres", cfg.DataSource())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -94,13 +103,14 @@ func TestAll(t *testing.T) {
 	defer teardownTestCase(t)
 
 	badUser := User{FirstName: proto.String("Bryan"), LastName: proto.String("Hughes"), Email: proto.String("bh@gmail.com")}
-	if err := badUser.Create(db); err == nil {
-		t.Fatal("Failed to catch error")
+	var notNullViolation *model.ErrNotNullViolation
+	if err := badUser.Create(ctx, db); !errors.As(err, &notNullViolation) || notNullViolation.Column != "user_token" {
+		t.Fatalf("Failed to catch error - %v", err)
 	}
 
 	var cases = []User{
-		{FirstName: proto.String("Bryan"), LastName: proto.String("Hughes"), Email: proto.String("bh@gmail.com"), UserToken: toPointer(newUUID().String()), Enabled: &enabled},
-		{FirstName: proto.String("Tom"), LastName: proto.String("Bagby"), Email: proto.String("tb@gmail.com"), UserToken: toPointer(newUUID().String()), Enabled: &enabled},
+		{FirstName: proto.String("Bryan"), LastName: proto.String("Hughes"), Email: proto.String("bh@gmail.com"), UserToken: toPointer(newUUID().String()), Enabled: &enabled, Tags: []string{"admin", "early adopter"}},
+		{FirstName: proto.String("Tom"), LastName: proto.String("Bagby"), Email: proto.String("tb@gmail.com"), UserToken: toPointer(newUUID().String()), Enabled: &enabled, Tags: []string{}},
 		{FirstName: proto.String("Alice"), LastName: proto.String("Tenfeet"), Email: proto.String("alice@tenfeet.com"), UserToken: toPointer(newUUID().String()), Enabled: &enabled},
 		{FirstName: proto.String("Mary"), LastName: proto.String("Littlelamb"), Email: proto.String("mary@gmail.com"), UserToken: toPointer(newUUID().String()), Enabled: &enabled},
 	}
@@ -115,13 +125,13 @@ func TestAll(t *testing.T) {
 	for i := 0; i < len(cases); i++ {
 		user = &cases[i]
 
-		err = user.Create(db)
+		err = user.Create(ctx, db)
 		if user.UserId == nil || err != nil {
 			t.Fatalf("Failed to create user record. Got a nil UserId instead of a database sequence - %s", err)
 		}
 
 		user1 := &User{}
-		err = user1.Read(db, user.UserId)
+		err = user1.Read(ctx, db, user.GetUserId())
 		if user.UserId == nil || err != nil {
 			t.Fatalf("Failed to read user record. Got back a nil UserId instead of %d - %s", user.UserId, err)
 		}
@@ -137,13 +147,14 @@ func TestAll(t *testing.T) {
 		user1.FirstName = &fName
 		user1.Lat = &lat
 		user1.Lon = &lon
+		user1.Tags = append(user1.Tags, fName)
 
-		err = user1.Update(db)
+		err = user1.Update(ctx, db)
 		if err != nil {
 			t.Fatalf("Failed to update user record - %s", err)
 		}
 
-		err = user1.Read(db, user.UserId)
+		err = user1.Read(ctx, db, user.GetUserId())
 		if err != nil {
 			t.Fatalf("Failed to read user record - %s", err)
 		}
@@ -160,13 +171,45 @@ func TestAll(t *testing.T) {
 			t.Fatal("Failed to read back lon change")
 		}
 
+		if len(user1.Tags) != len(user.Tags)+1 || user1.Tags[len(user.Tags)] != fName {
+			t.Fatalf("Failed to read back tags change, got %q", user1.Tags)
+		}
+
+		if *user1.Version != *user.Version+1 {
+			t.Fatalf("Expected the version to be moved forward from %d but got %d", *user.Version, *user1.Version)
+		}
+		if err := user.Update(ctx, db); !errors.Is(err, model.ErrStaleWrite) {
+			t.Fatalf("Should not have updated with the version that was read before the update - %v", err)
+		}
+		if count, err := user.Delete(ctx, db); count != 0 || !errors.Is(err, model.ErrStaleWrite) {
+			t.Fatalf("Should not have deleted with the version that was read before the update - %v", err)
+		}
+
 		cases[i] = *user1
 	}
 
+	// Test constraint violations
+	duplicate := User{FirstName: proto.String("Bryan"), LastName: proto.String("Hughes"), Email: cases[0].Email,
+		UserToken: toPointer(newUUID().String()), Enabled: &enabled}
+	var uniqueViolation *model.ErrUniqueViolation
+	err = duplicate.Create(ctx, db)
+	if !errors.As(err, &uniqueViolation) || uniqueViolation.Constraint != "lookup_email" ||
+		!reflect.DeepEqual(uniqueViolation.Columns, []string{"email"}) {
+		t.Fatalf("Expected a unique violation of lookup_email - %v", err)
+	}
+
+	missingAka := int32(-1)
+	duplicate.Email = proto.String("aka@gmail.com")
+	duplicate.AkaId = &missingAka
+	var fkViolation *model.ErrForeignKeyViolation
+	if err = duplicate.Create(ctx, db); !errors.As(err, &fkViolation) || fkViolation.Constraint != "fk_user_user" {
+		t.Fatalf("Expected a foreign key violation of fk_user_user - %v", err)
+	}
+
 	// Test lookups
 	user = &cases[1]
 	user1 := &User{}
-	err = user1.LookupEmail(db, user.Email)
+	err = user1.LookupEmail(ctx, db, user.GetEmail())
 	if user1.UserId == nil || err != nil {
 		t.Fatalf("Failed to lookup user record. Got back a nil UserId instead of %d - %s", user1.UserId, err)
 	}
@@ -175,28 +218,79 @@ func TestAll(t *testing.T) {
 		t.Fatal("lookup up does not match")
 	}
 
+	named, err := LookupUsersByName(ctx, db, user.GetFirstName(), user.GetLastName(), 10)
+	if len(named) != 1 || err != nil || !reflect.DeepEqual(user, &named[0]) {
+		t.Fatalf("Expected to lookup the user by name - %v", err)
+	}
+
 	var list []User
-	var cnt int32
-	list, cnt, err = ListUsers(db, 100, 0)
+	var next string
+	list, next, err = ListUsers(ctx, db, 3, "")
+	if len(list) != 3 || next == "" || err != nil {
+		t.Fatalf("Expected a page of 3 users and a cursor but got %d - %v", len(list), err)
+	}
+
+	var page []User
+	page, next, err = ListUsers(ctx, db, 3, next)
+	if len(page) != 1 || next != "" || err != nil {
+		t.Fatalf("Expected a last page of 1 user but got %d, %q - %v", len(page), next, err)
+	}
+	list = append(list, page...)
+	for i := 1; i < len(list); i++ {
+		if *list[i-1].UserId >= *list[i].UserId {
+			t.Fatalf("Expected the users to be ordered by user_id but got %d before %d", *list[i-1].UserId,
+				*list[i].UserId)
+		}
+	}
 
-	if len(list) != 4 {
-		t.Fatalf("Expected 4 users but got %d", len(list))
+	if _, _, err = ListUsers(ctx, db, 3, "not a cursor"); !errors.Is(err, model.ErrInvalidCursor) {
+		t.Fatalf("Expected model.ErrInvalidCursor but got %v", err)
 	}
 
-	if cnt != 5 {
-		t.Fatalf("Expected 5 cnt but got %d", cnt)
+	list, next, err = ListUsers(ctx, db, math.MaxInt32, "")
+	if len(list) != 4 || next != "" || err != nil {
+		t.Fatalf("Expected a single page of 4 users but got %d, %q - %v", len(list), next, err)
+	}
+
+	like := "%@gmail.com"
+	notNull := false
+	list, err = FindUsers(ctx, db, &UserFilter{Email: &model.StringFilter{Like: &like},
+		UserId: &model.Int32Filter{IsNull: &notNull}}, []model.Sort{{Column: "email", Desc: true}}, 2, 1)
+	if len(list) != 2 || err != nil {
+		t.Fatalf("Expected 2 users but got %d - %v", len(list), err)
+	}
+	if *list[0].Email < *list[1].Email {
+		t.Fatalf("Expected the users to be sorted by email descending but got %s before %s", *list[0].Email,
+			*list[1].Email)
+	}
+	if _, err = FindUsers(ctx, db, nil, []model.Sort{{Column: "pword_hash"}}, 2, 0); !errors.Is(err,
+		model.ErrInvalidSort) {
+		t.Fatalf("Expected model.ErrInvalidSort but got %v", err)
+	}
+	list, err = FindUsers(ctx, db, nil, nil, math.MaxInt32, 0)
+	if len(list) != 4 || err != nil {
+		t.Fatalf("Expected 4 users but got %d - %v", len(list), err)
+	}
+
+	var total int64
+	total, err = CountUsers(ctx, db, true)
+	if total != 4 || err != nil {
+		t.Fatalf("Expected 4 users but got %d - %v", total, err)
+	}
+	if _, err = CountUsers(ctx, db, false); err != nil {
+		t.Fatalf("Failed to estimate the number of users - %v", err)
 	}
 
 	var count int64
 	u := newUUID()
 	bvalue, _ := u.MarshalBinary()
-	count, err = UpdatePasswordHash(db, user.Email, bvalue)
-	if count != 1 {
-		t.Fatal("Expected 1 update")
+	count, err = UpdatePwordHash(ctx, db, bvalue, *user.Email)
+	if count != 1 || err != nil {
+		t.Fatalf("Expected 1 update - %v", err)
 	}
 
 	var results []map[string]interface{}
-	results, err = GetPasswordHash(db, user.Email)
+	results, err = GetPwordHash(ctx, db, *user.Email)
 	if results == nil {
 		t.Fatalf("Expected a non nil result - %s", err)
 	}
@@ -206,7 +300,7 @@ func TestAll(t *testing.T) {
 		t.Fatalf("Got %s instead of %s", v, u)
 	}
 
-	results, err = FindNearest(db, -122.388983, 37.763964, 5)
+	results, err = FindNearest(ctx, db, -122.388983, 37.763964, 5)
 	if results == nil {
 		t.Fatalf("Expected a non nil result - %s", err)
 	}
@@ -222,7 +316,7 @@ func TestAll(t *testing.T) {
 		t.Fatalf("Got %d instead of %d", v1, *user.UserId)
 	}
 
-	results, err = SetToken(db, *user.UserId)
+	results, err = SetToken(ctx, db, *user.UserId)
 	if results == nil {
 		t.Fatalf("Expected a non nil result - %s", err)
 	}
@@ -237,13 +331,10 @@ func TestAll(t *testing.T) {
 		t.Fatal("Expected a UUID string which is 36 byte/chars")
 	}
 
-	results, err = DisableUser(db, *user.UserId)
-	if results == nil {
-		t.Fatalf("Expected a non nil result - %s", err)
-	}
-
-	if len(results) != 0 {
-		t.Fatal("Should of had no results (i.e. no 'RETURNING' or 'SELECT' was in the query")
+	// A mapping without a RETURNING or SELECT returns the number of rows it changed
+	count, err = DisableUser(ctx, db, *user.Email)
+	if count != 1 || err != nil {
+		t.Fatalf("Expected 1 user to be disabled - %v", err)
 	}
 
 	// Test delete
@@ -251,20 +342,286 @@ func TestAll(t *testing.T) {
 		user = &cases[i]
 
 		var count int64
-		count, err = user.Delete(db)
+		count, err = user.Delete(ctx, db)
 		if count != 1 || err != nil {
 			t.Fatalf("Failed to delete user record. Got back a nil UserId instead of %d - %s", user.UserId, err)
 		}
 
-		err = user.Read(db, user.UserId)
-		if err != nil {
-			t.Fatalf("Failed to read user record - %s", err)
+		err = user.Read(ctx, db, user.GetUserId())
+		if !errors.Is(err, model.ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("Should not have read - %v", err)
 		}
 
-		if user.UserId != nil {
-			t.Fatal("Should not have read")
+		err = user.Update(ctx, db)
+		if !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("Should not have updated a deleted user - %v", err)
 		}
 	}
 
 	t.Log("----------- TestAll done -----------")
 }
+
+func TestListLimit(t *testing.T) {
+	// The limit is checked before the query, so no database is needed
+	for _, limit := range []int32{0, -1, math.MinInt32} {
+		if _, _, err := ListUsers(ctx, nil, limit, ""); !errors.Is(err, model.ErrInvalidLimit) {
+			t.Errorf("Expected model.ErrInvalidLimit for a limit of %d but got %v", limit, err)
+		}
+		if _, err := FindUsers(ctx, nil, nil, nil, limit, 0); !errors.Is(err, model.ErrInvalidLimit) {
+			t.Errorf("Expected model.ErrInvalidLimit for a limit of %d but got %v", limit, err)
+		}
+	}
+}
+
+func TestNoVersion(t *testing.T) {
+	// A user without a version is rejected before the query, so no database is needed
+	userId := int32(1)
+	user := User{UserId: &userId, Email: toPointer("bh@gmail.com"), UserToken: toPointer(newUUID().String()),
+		Enabled: &enabled}
+
+	if err := user.Update(ctx, nil); !errors.Is(err, model.ErrNoVersion) {
+		t.Errorf("Expected model.ErrNoVersion from Update but got %v", err)
+	}
+	if err := user.UpdateMask(ctx, nil, &fieldmaskpb.FieldMask{Paths: []string{"email"}}); !errors.Is(err,
+		model.ErrNoVersion) {
+		t.Errorf("Expected model.ErrNoVersion from UpdateMask but got %v", err)
+	}
+	if _, err := user.Delete(ctx, nil); !errors.Is(err, model.ErrNoVersion) {
+		t.Errorf("Expected model.ErrNoVersion from Delete but got %v", err)
+	}
+}
+
+// A driver that estimates the count of a table as the name that it is opened with, and counts 3 rows
+type countDriver struct{}
+
+func (countDriver) Open(name string) (driver.Conn, error) { return countConn(name), nil }
+
+type countConn string
+
+func (countConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (countConn) Close() error                              { return nil }
+func (countConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+func (c countConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query == userEstimateCountStr {
+		estimate, err := strconv.ParseInt(string(c), 10, 64)
+		return &countRows{count: estimate}, err
+	}
+	return &countRows{count: 3}, nil
+}
+
+type countRows struct {
+	count int64
+	done  bool
+}
+
+func (r *countRows) Columns() []string { return []string{"count"} }
+func (r *countRows) Close() error      { return nil }
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0], r.done = r.count, true
+	return nil
+}
+
+var registerCount sync.Once
+
+func TestCountEstimate(t *testing.T) {
+	// The queries are answered by countDriver, so no database is needed
+	registerCount.Do(func() { sql.Register("test_schema_count", countDriver{}) })
+
+	cases := []struct {
+		estimate string
+		exact    bool
+		expected int64
+	}{
+		{"12", false, 12},
+		{"12", true, 3},
+		{"0", false, 3},
+		{"-1", false, 3},
+	}
+	for i, c := range cases {
+		conn, err := sql.Open("test_schema_count", c.estimate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count, err := CountUsers(ctx, conn, c.exact); err != nil || count != c.expected {
+			t.Errorf("%d) Expected %d but got %d - %v", i, c.expected, count, err)
+		}
+		_ = conn.Close()
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := User{FirstName: proto.String("Tom"), LastName: proto.String("Thumb"), Email: proto.String("tt@gmail.com"),
+		UserToken: toPointer(newUUID().String()), Enabled: &enabled}
+	if err := user.Create(ctx, tx); err != nil {
+		t.Fatalf("Failed to create user record in the transaction - %s", err)
+	}
+
+	user1 := &User{}
+	if err := user1.Read(ctx, tx, user.GetUserId()); err != nil || user1.UserId == nil {
+		t.Fatalf("Failed to read user record in the transaction - %s", err)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := user1.Read(ctx, db, user.GetUserId()); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("Should not have read a user that was rolled back - %v", err)
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+
+	user := User{FirstName: proto.String("Jack"), LastName: proto.String("Sprat"), Email: proto.String("js@gmail.com"),
+		UserToken: toPointer(newUUID().String()), Enabled: &enabled}
+	badUser := User{FirstName: proto.String("Jill"), LastName: proto.String("Sprat"), Email: proto.String("jill@gmail.com")}
+
+	err := model.WithTx(ctx, db, &model.TxOptions{MaxRetries: 3}, func(tx model.DBTX) error {
+		if err := user.Create(ctx, tx); err != nil {
+			return err
+		}
+		return badUser.Create(ctx, tx)
+	})
+	if err == nil {
+		t.Fatal("Failed to catch error")
+	}
+
+	user1 := &User{}
+	if err := user1.LookupEmail(ctx, db, user.GetEmail()); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("Should not have read a user that was rolled back - %v", err)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+
+	user := User{FirstName: proto.String("Little"), LastName: proto.String("Bopeep"), Email: proto.String("lb@gmail.com"),
+		UserToken: toPointer(newUUID().String()), Enabled: &enabled}
+	again := User{FirstName: proto.String("Bo"), LastName: proto.String("Peep"), Email: user.Email,
+		UserToken: toPointer(newUUID().String()), Enabled: &enabled}
+	defer func() {
+		_, _ = again.Delete(ctx, db)
+		_, _ = user.Delete(ctx, db)
+	}()
+
+	if err := user.UpsertByEmail(ctx, db); err != nil || user.UserId == nil {
+		t.Fatalf("Failed to upsert a new user - %v", err)
+	}
+	if err := again.UpsertByEmail(ctx, db); err != nil {
+		t.Fatalf("Failed to upsert an existing user - %v", err)
+	}
+	if *again.UserId != *user.UserId || *again.FirstName != "Bo" || *again.Version != *user.Version+1 {
+		t.Fatalf("Expected user %d to be overwritten but got user %d named %s", *user.UserId, *again.UserId,
+			*again.FirstName)
+	}
+}
+
+func TestBulkCreate(t *testing.T) {
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+
+	users := make([]*User, 0)
+	for _, name := range []string{"Huey", "Dewey", "Louie"} {
+		users = append(users, &User{FirstName: proto.String(name), LastName: proto.String("Duck"),
+			Email: proto.String(name + "@duck.com"), UserToken: toPointer(newUUID().String()), Enabled: &enabled})
+	}
+	if err := CreateUsers(ctx, db, users); err != nil {
+		t.Fatalf("Failed to create the users - %v", err)
+	}
+	for _, user := range users {
+		defer func(user *User) { _, _ = user.Delete(ctx, db) }(user)
+		if user.UserId == nil {
+			t.Fatalf("Expected %s to have a user_id", *user.FirstName)
+		}
+	}
+
+	duplicate := []*User{{Email: proto.String("Scrooge@duck.com"), UserToken: toPointer(newUUID().String()),
+		Enabled: &enabled}, {Email: users[0].Email, UserToken: toPointer(newUUID().String()), Enabled: &enabled}}
+	var unique *model.ErrUniqueViolation
+	if err := CreateUsers(ctx, db, duplicate); !errors.As(err, &unique) {
+		t.Fatalf("Expected a unique violation but got %v", err)
+	}
+	user := &User{}
+	if err := user.LookupEmail(ctx, db, duplicate[0].GetEmail()); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("Expected none of the users to be created - %v", err)
+	}
+
+	lon, lat := -122.388983, 37.763964
+	copied := []*User{{FirstName: proto.String("Donald"), Email: proto.String("donald@duck.com"),
+		UserToken: toPointer(newUUID().String()), Enabled: &enabled, Lon: &lon, Lat: &lat},
+		{FirstName: proto.String("Daisy"), Email: proto.String("daisy@duck.com"),
+			UserToken: toPointer(newUUID().String()), Enabled: &enabled}}
+	count, err := CopyUsers(ctx, db, copied)
+	if count != 2 || err != nil {
+		t.Fatalf("Expected 2 users to be copied but got %d - %v", count, err)
+	}
+	if err := user.LookupEmail(ctx, db, copied[0].GetEmail()); err != nil || user.Lat == nil || *user.Lat != lat {
+		t.Fatalf("Expected the location of the copied user to be written - %v", err)
+	}
+	_, _ = user.Delete(ctx, db)
+	if err := user.LookupEmail(ctx, db, copied[1].GetEmail()); err == nil {
+		_, _ = user.Delete(ctx, db)
+	}
+}
+
+func TestUpdateMask(t *testing.T) {
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+
+	user := User{FirstName: proto.String("Humpty"), LastName: proto.String("Dumpty"), Email: proto.String("hd@gmail.com"),
+		UserToken: toPointer(newUUID().String()), Enabled: &enabled}
+	if err := user.Create(ctx, db); err != nil {
+		t.Fatalf("Failed to create user record - %v", err)
+	}
+
+	partial := User{UserId: user.UserId, FirstName: proto.String("Humphrey"), Version: user.Version}
+	defer func() {
+		_, _ = partial.Delete(ctx, db)
+		_, _ = user.Delete(ctx, db)
+	}()
+	if err := partial.UpdateMask(ctx, db, &fieldmaskpb.FieldMask{Paths: []string{"first_name"}}); err != nil {
+		t.Fatalf("Failed to update the first name - %v", err)
+	}
+	if *partial.FirstName != "Humphrey" || partial.LastName == nil || *partial.LastName != "Dumpty" ||
+		*partial.Email != *user.Email {
+		t.Fatalf("Expected only the first name to be updated but got %v", &partial)
+	}
+
+	stale := User{UserId: user.UserId, LastName: proto.String("Dumpling"), Version: user.Version}
+	if err := stale.UpdateMask(ctx, db, &fieldmaskpb.FieldMask{Paths: []string{"last_name"}}); !errors.Is(err,
+		model.ErrStaleWrite) {
+		t.Fatalf("Should not have updated with the version that was read before the update - %v", err)
+	}
+
+	partial.Tags = []string{"egg", "wall"}
+	if err := partial.UpdateMask(ctx, db, &fieldmaskpb.FieldMask{Paths: []string{"tags"}}); err != nil ||
+		!reflect.DeepEqual(partial.Tags, []string{"egg", "wall"}) || *partial.FirstName != "Humphrey" {
+		t.Fatalf("Expected only the tags to be updated but got %v - %v", &partial, err)
+	}
+
+	if err := partial.UpdateMask(ctx, db, &fieldmaskpb.FieldMask{Paths: []string{"lat"}}); !errors.Is(err,
+		model.ErrInvalidMask) {
+		t.Fatalf("Expected lat without lon to be rejected but got %v", err)
+	}
+
+	var notNull *model.ErrNotNullViolation
+	partial.Email = nil
+	if err := partial.UpdateMask(ctx, db, &fieldmaskpb.FieldMask{Paths: []string{"email"}}); !errors.As(err,
+		&notNull) {
+		t.Fatalf("Expected a not null violation of email but got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// TxOptions How WithTx runs a transaction. A transaction that fails with a serialization failure or a deadlock is run
+// again up to MaxRetries times, waiting Backoff before the first retry and twice as long before each one after it.
+type TxOptions struct {
+	Isolation  sql.IsolationLevel
+	ReadOnly   bool
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// The wait before the first retry when TxOptions.Backoff is not set
+const defaultBackoff = 10 * time.Millisecond
+
+// TxBeginner Starts a transaction, which is done by *sql.DB and *sql.Conn
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// The transaction handed to the function of WithTx. It remembers how deeply WithTx is nested, so that each nested call
+// uses its own savepoint.
+type txHandle struct {
+	*sql.Tx
+	depth int
+}
+
+// WithTx Runs fn in a transaction, which is committed when fn returns nil and rolled back when it returns an error or
+// panics. When db is a *sql.DB or a *sql.Conn a transaction is started, while when it is a transaction, such as the tx
+// given to fn, the nested call runs in a savepoint that is released or rolled back to. Only the outermost call retries,
+// since a serialization failure or a deadlock aborts the whole transaction. opts may be nil.
+func WithTx(ctx context.Context, db DBTX, opts *TxOptions, fn func(tx DBTX) error) error {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+
+	switch handle := db.(type) {
+	case *txHandle:
+		return withSavepoint(ctx, handle.Tx, handle.depth+1, fn)
+	case *sql.Tx:
+		return withSavepoint(ctx, handle, 1, fn)
+	case TxBeginner:
+		backoff := opts.Backoff
+		if backoff <= 0 {
+			backoff = defaultBackoff
+		}
+
+		for attempt := 0; ; attempt++ {
+			err := withTx(ctx, handle, opts, fn)
+			if err == nil || attempt >= opts.MaxRetries || !IsRetryable(err) {
+				return err
+			}
+
+			// Jitter keeps the transactions that conflicted from retrying in lockstep
+			wait := backoff<<attempt + time.Duration(rand.Int63n(int64(backoff)))
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+		}
+	default:
+		return fmt.Errorf("model: cannot start a transaction on a %T", db)
+	}
+}
+
+func withTx(ctx context.Context, db TxBeginner, opts *TxOptions, fn func(tx DBTX) error) (err error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&txHandle{Tx: tx}); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func withSavepoint(ctx context.Context, tx *sql.Tx, depth int, fn func(tx DBTX) error) (err error) {
+	savepoint := fmt.Sprintf("model_savepoint_%d", depth)
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint)
+			panic(p)
+		}
+	}()
+
+	if err := fn(&txHandle{Tx: tx, depth: depth}); err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint)
+	return err
+}
+
+// The SQLSTATE of a serialization failure and of a detected deadlock
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// IsRetryable Returns true when the error is a serialization failure or a deadlock, where running the transaction
+// again may well succeed
+func IsRetryable(err error) bool {
+	state := SQLState(err)
+	return state == sqlStateSerializationFailure || state == sqlStateDeadlockDetected
+}
+
+// SQLState Returns the SQLSTATE of a database error, or an empty string when the error does not have one. The error
+// of the driver, which may be wrapped, must have a SQLState() method as *pq.Error does
+func SQLState(err error) string {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
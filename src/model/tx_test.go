@@ -0,0 +1,190 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A driver that records the statements and transactions of its connection. The first failCommits commits fail with a
+// serialization failure
+type recordingDriver struct {
+	mu          sync.Mutex
+	log         []string
+	failCommits int
+}
+
+type stateError string
+
+func (e stateError) Error() string    { return "pq: could not serialize access (" + string(e) + ")" }
+func (e stateError) SQLState() string { return string(e) }
+
+func (d *recordingDriver) record(entry string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, entry)
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c.d, query}, nil
+}
+func (c *recordingConn) Close() error { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+func (c *recordingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.record("BEGIN")
+	return c, nil
+}
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result,
+	error) {
+	c.d.record(query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *recordingConn) Commit() error {
+	c.d.mu.Lock()
+	fail := c.d.failCommits > 0
+	c.d.failCommits--
+	c.d.mu.Unlock()
+	if fail {
+		c.d.record("COMMIT failed")
+		return stateError(sqlStateSerializationFailure)
+	}
+	c.d.record("COMMIT")
+	return nil
+}
+
+func (c *recordingConn) Rollback() error {
+	c.d.record("ROLLBACK")
+	return nil
+}
+
+// A prepared statement, which records the values it is executed with
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.record(fmt.Sprintf("%s %v", s.query, args))
+	return driver.RowsAffected(1), nil
+}
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+var registerOnce sync.Once
+var recorder = &recordingDriver{}
+
+func openRecorder(t *testing.T, failCommits int) *sql.DB {
+	registerOnce.Do(func() { sql.Register("model_recorder", recorder) })
+	recorder.log, recorder.failCommits = nil, failCommits
+
+	db, err := sql.Open("model_recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	db := openRecorder(t, 0)
+
+	failed := errors.New("address is invalid")
+	err := WithTx(ctx, db, nil, func(tx DBTX) error {
+		if _, err := tx.ExecContext(ctx, "INSERT user"); err != nil {
+			return err
+		}
+		if err := WithTx(ctx, tx, nil, func(tx DBTX) error {
+			_, _ = tx.ExecContext(ctx, "INSERT address")
+			return failed
+		}); !errors.Is(err, failed) {
+			t.Errorf("Expected the error of the nested call but got %v", err)
+		}
+		return WithTx(ctx, tx, nil, func(tx DBTX) error {
+			return WithTx(ctx, tx, nil, func(tx DBTX) error {
+				_, err := tx.ExecContext(ctx, "INSERT phone")
+				return err
+			})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"BEGIN", "INSERT user",
+		"SAVEPOINT model_savepoint_1", "INSERT address", "ROLLBACK TO SAVEPOINT model_savepoint_1",
+		"SAVEPOINT model_savepoint_1", "SAVEPOINT model_savepoint_2", "INSERT phone",
+		"RELEASE SAVEPOINT model_savepoint_2", "RELEASE SAVEPOINT model_savepoint_1", "COMMIT"}
+	if !reflect.DeepEqual(recorder.log, expected) {
+		t.Errorf("Expected\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(recorder.log, "\n"))
+	}
+
+	recorder.log = nil
+	if err := WithTx(ctx, db, nil, func(tx DBTX) error { return failed }); !errors.Is(err, failed) {
+		t.Errorf("Expected %v but got %v", failed, err)
+	}
+	if !reflect.DeepEqual(recorder.log, []string{"BEGIN", "ROLLBACK"}) {
+		t.Errorf("Expected the transaction to be rolled back but got %v", recorder.log)
+	}
+}
+
+func TestWithTxRetry(t *testing.T) {
+	ctx := context.Background()
+	db := openRecorder(t, 2)
+
+	attempts := 0
+	err := WithTx(ctx, db, &TxOptions{MaxRetries: 3, Backoff: 1}, func(tx DBTX) error {
+		attempts++
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("Expected to succeed on the third attempt but got %d attempts - %v", attempts, err)
+	}
+
+	recorder.failCommits = 2
+	attempts = 0
+	err = WithTx(ctx, db, &TxOptions{MaxRetries: 1, Backoff: 1}, func(tx DBTX) error {
+		attempts++
+		return nil
+	})
+	if !IsRetryable(err) || attempts != 2 {
+		t.Errorf("Expected to give up after 2 attempts but got %d attempts - %v", attempts, err)
+	}
+
+	attempts = 0
+	failed := errors.New("constraint")
+	err = WithTx(ctx, db, &TxOptions{MaxRetries: 3, Backoff: 1}, func(tx DBTX) error {
+		attempts++
+		return failed
+	})
+	if !errors.Is(err, failed) || attempts != 1 {
+		t.Errorf("Expected no retry of an error that is not retryable but got %d attempts - %v", attempts, err)
+	}
+}
+
+func TestSQLState(t *testing.T) {
+	wrapped := fmt.Errorf("user_db: create: %w", stateError(sqlStateDeadlockDetected))
+	if SQLState(wrapped) != "40P01" || !IsRetryable(wrapped) {
+		t.Errorf("Expected a wrapped deadlock to be retryable")
+	}
+	if SQLState(errors.New("plain")) != "" || IsRetryable(nil) {
+		t.Errorf("Expected an error without a SQLSTATE not to be retryable")
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"database/sql"
 	"github.com/bryanhughes/go_dbmap/src/model"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -261,3 +262,117 @@ func TestTime(t *testing.T) {
 		t.Error("expected nil")
 	}
 }
+
+func TestParseComposite(t *testing.T) {
+	fields, err := model.ParseComposite(`(1,"main street, apt 2",,"","say ""hi""",a\,b)`)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	expected := []sql.NullString{
+		{String: "1", Valid: true},
+		{String: "main street, apt 2", Valid: true},
+		{String: "", Valid: false},
+		{String: "", Valid: true},
+		{String: `say "hi"`, Valid: true},
+		{String: "a,b", Valid: true},
+	}
+	if len(fields) != len(expected) {
+		t.Fatalf("expected %d fields got %d", len(expected), len(fields))
+	}
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Errorf("%d) expected %v got %v", i, expected[i], fields[i])
+		}
+	}
+
+	if _, err := model.ParseComposite("1,2"); err == nil {
+		t.Error("expected an error for a value without parentheses")
+	}
+
+	if _, err := model.ParseComposite(`("1,2)`); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
+
+func TestCompositeValue(t *testing.T) {
+	composite := model.Composite{
+		Fields: []sql.NullString{
+			{String: "1", Valid: true},
+			{String: "main street", Valid: true},
+			{Valid: false},
+			{String: "", Valid: true},
+			{String: `a "b"`, Valid: true},
+		},
+		Valid: true,
+	}
+
+	v, err := composite.Value()
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if v != `(1,"main street",,"","a ""b""")` {
+		t.Errorf("unexpected value %s", v)
+	}
+
+	var scanned model.Composite
+	if err := scanned.Scan([]byte(v.(string))); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(scanned, composite) {
+		t.Errorf("expected %v got %v", composite, scanned)
+	}
+
+	if err := scanned.Scan(nil); err != nil || scanned.Valid {
+		t.Error("expected a NULL composite")
+	}
+
+	if v, _ := scanned.Value(); v != nil {
+		t.Error("expected nil")
+	}
+}
+
+func TestCompositeFields(t *testing.T) {
+	composite, err := model.NewComposite(sql.NullInt32{Int32: 7, Valid: true},
+		sql.NullString{String: `main street, "north"`, Valid: true}, sql.NullString{},
+		sql.NullBool{Bool: true, Valid: true}, sql.NullFloat64{Float64: 1.5, Valid: true})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	v, err := composite.Value()
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if v != `(7,"main street, ""north""",,true,1.5)` {
+		t.Errorf("unexpected value %s", v)
+	}
+
+	// postgres writes a bool as t or f
+	var scanned model.Composite
+	if err := scanned.Scan(`(7,"main street, ""north""",,t,1.5)`); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var number sql.NullInt32
+	var street, empty sql.NullString
+	var enabled sql.NullBool
+	var ratio sql.NullFloat64
+	if err := scanned.ScanFields(&number, &street, &empty, &enabled, &ratio); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if number.Int32 != 7 || street.String != `main street, "north"` || empty.Valid || !enabled.Bool ||
+		ratio.Float64 != 1.5 {
+		t.Errorf("unexpected fields %v %v %v %v %v", number, street, empty, enabled, ratio)
+	}
+
+	if err := scanned.ScanFields(&number); err == nil {
+		t.Error("expected an error scanning 5 attributes into 1 field")
+	}
+}
+
+func TestDBTX(t *testing.T) {
+	handles := []model.DBTX{(*sql.DB)(nil), (*sql.Tx)(nil), (*sql.Conn)(nil)}
+	if len(handles) != 3 {
+		t.Error("expected *sql.DB, *sql.Tx and *sql.Conn to be a model.DBTX")
+	}
+}